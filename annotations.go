@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AnnotationsConfig controls the index of TODO, FIXME and similar comments.
+// Tags are added to the default ones, Blame adds the author of the line from git blame.
+type AnnotationsConfig struct {
+	Enabled bool     `json:"enabled,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Blame   bool     `json:"blame,omitempty"`
+}
+
+// Return a copy of the settings with values of other applied; tags are added to the existing ones
+func (a AnnotationsConfig) override(other AnnotationsConfig) AnnotationsConfig {
+	if other.Enabled {
+		a.Enabled = true
+	}
+	if other.Blame {
+		a.Blame = true
+	}
+	a.Tags = append(append([]string{}, a.Tags...), other.Tags...)
+	return a
+}
+
+// Annotation is a tagged comment like "TODO(alice): handle errors"
+type Annotation struct {
+	Path   string
+	Line   int
+	Tag    string
+	Owner  string
+	Text   string
+	Author string
+	Date   time.Time
+}
+
+// Label of the annotation, the tag with its owner
+func (a Annotation) label() string {
+	if a.Owner != "" {
+		return a.Tag + "(" + a.Owner + ")"
+	}
+	return a.Tag
+}
+
+// Ends of block comments removed from the annotation text
+var commentClosers = []string{"*/", "-->", "-}", "*)", "#>"}
+
+// Build the pattern of a tag at the start of a comment line, after the comment markers.
+// The tag may be followed by an owner in parentheses and a colon.
+func annotationRegexp(tags []string) (*regexp.Regexp, error) {
+	quoted := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			return nil, errors.New("empty annotation tag")
+		}
+		quoted = append(quoted, regexp.QuoteMeta(strings.TrimSpace(tag)))
+	}
+	// Longer tags first, so that a tag is not matched by its prefix
+	sort.SliceStable(quoted, func(i, j int) bool {
+		return len(quoted[i]) > len(quoted[j])
+	})
+	return regexp.Compile(`^[^\p{L}\p{N}]*?(` + strings.Join(quoted, "|") + `)(?:\(([^)]*)\))?(?:[^\p{L}\p{N}_(]|$)(.*)$`)
+}
+
+// Collect annotations from the comments of a file. Comments are recognized by the syntax of the language,
+// files of other languages have no annotations. Lines added by project2md are skipped.
+func findAnnotations(lines []SourceLine, language string, pattern *regexp.Regexp) []Annotation {
+	if !canStrip(language) || len(lines) == 0 {
+		return nil
+	}
+
+	text := joinLines(lines)
+	var comments []commentSpan
+	if language == "go" {
+		comments, _ = scanGoComments(text)
+	} else {
+		comments, _ = scanComments(text, commentSyntaxes[language])
+	}
+
+	starts := make([]int, len(lines))
+	offset := 0
+	for i, line := range lines {
+		starts[i] = offset
+		offset += len(line.Text) + 1
+	}
+
+	var annotations []Annotation
+	for _, comment := range comments {
+		index := sort.SearchInts(starts, comment.start+1) - 1
+		for i, commentLine := range strings.Split(text[comment.start:comment.end], "\n") {
+			number := lines[index+i].Number
+			match := pattern.FindStringSubmatch(commentLine)
+			if match == nil || number == 0 {
+				continue
+			}
+			annotations = append(annotations, Annotation{
+				Line:  number,
+				Tag:   match[1],
+				Owner: strings.TrimSpace(match[2]),
+				Text:  annotationText(match[3]),
+			})
+		}
+	}
+	return annotations
+}
+
+// Clean up the text after a tag: separators before it and the end of a block comment after it
+func annotationText(text string) string {
+	text = strings.TrimSpace(text)
+	for _, closer := range commentClosers {
+		text = strings.TrimSpace(strings.TrimSuffix(text, closer))
+	}
+	return strings.TrimSpace(strings.TrimLeft(text, ":-"))
+}
+
+// Anchor of a file section linked from the annotations, unique among the used ones
+func fileAnchor(relPath string, used map[string]bool) string {
+	var builder strings.Builder
+	builder.WriteString("file")
+	separator := true
+	for _, r := range strings.ToLower(relPath) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if separator {
+				builder.WriteByte('-')
+			}
+			builder.WriteRune(r)
+			separator = false
+		} else {
+			separator = true
+		}
+	}
+
+	anchor := builder.String()
+	for i := 2; used[anchor]; i++ {
+		anchor = builder.String() + "-" + strconv.Itoa(i)
+	}
+	used[anchor] = true
+	return anchor
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Actions for files detected as binary
+const (
+	binarySkip        = "skip"
+	binaryPlaceholder = "placeholder"
+)
+
+// BinaryConfig controls content based binary detection.
+// Disabled and MaxInvalidUTF8 are pointers, so that an override can set them back to false and 0.
+type BinaryConfig struct {
+	Action         string                  `json:"action,omitempty"`
+	Disabled       *bool                   `json:"disabled,omitempty"`
+	SniffBytes     int                     `json:"sniff_bytes,omitempty"`
+	MaxInvalidUTF8 *float64                `json:"max_invalid_utf8,omitempty"`
+	Extensions     map[string]BinaryConfig `json:"extensions,omitempty"`
+}
+
+// Return a copy of the settings with the values set in other applied
+func (b BinaryConfig) override(other BinaryConfig) BinaryConfig {
+	if other.Action != "" {
+		b.Action = other.Action
+	}
+	if other.Disabled != nil {
+		b.Disabled = other.Disabled
+	}
+	if other.SniffBytes > 0 {
+		b.SniffBytes = other.SniffBytes
+	}
+	if other.MaxInvalidUTF8 != nil {
+		b.MaxInvalidUTF8 = other.MaxInvalidUTF8
+	}
+	return b
+}
+
+// Content types reported by http.DetectContentType that are still text
+var textContentTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/postscript",
+}
+
+// Sniff the beginning of the content and tell whether it looks binary, with the reason
+func detectBinary(content []byte, settings BinaryConfig) (isBinary bool, reason string) {
+	if (settings.Disabled != nil && *settings.Disabled) || len(content) == 0 {
+		return false, ""
+	}
+
+	sample := content
+	if settings.SniffBytes > 0 && len(sample) > settings.SniffBytes {
+		sample = sample[:settings.SniffBytes]
+	}
+
+	// Catches known binary signatures and control characters including NUL
+	contentType := http.DetectContentType(sample)
+	isText := false
+	for _, prefix := range textContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			isText = true
+			break
+		}
+	}
+	if !isText {
+		return true, contentType
+	}
+	if strings.Contains(contentType, "utf-16") {
+		return true, "UTF-16 text"
+	}
+
+	if maxInvalid := settings.MaxInvalidUTF8; maxInvalid != nil && *maxInvalid > 0 {
+		if ratio := invalidUTF8Ratio(sample, len(sample) < len(content)); ratio > *maxInvalid {
+			return true, fmt.Sprintf("%.0f%% invalid UTF-8", ratio*100)
+		}
+	}
+	return false, ""
+}
+
+// Share of bytes that are not part of valid UTF-8 sequences.
+// A sequence cut at the end of a truncated sample is not counted.
+func invalidUTF8Ratio(sample []byte, truncated bool) float64 {
+	invalid := 0
+	for i := 0; i < len(sample); {
+		r, size := utf8.DecodeRune(sample[i:])
+		if r == utf8.RuneError && size == 1 {
+			if truncated && !utf8.FullRune(sample[i:]) {
+				break
+			}
+			invalid++
+		}
+		i += size
+	}
+	return float64(invalid) / float64(len(sample))
+}
@@ -14,6 +14,19 @@ type Config struct {
 	Include        map[string]struct{} `json:"-"`
 	SkipDirs       map[string]bool     `json:"skip_dirs"`
 	Languages      map[string]string   `json:"languages"`
+	Submodules     string              `json:"submodules,omitempty"`
+	GitAttributes  map[string]string   `json:"gitattributes"`
+	LFS            string              `json:"lfs,omitempty"`
+	Binary         BinaryConfig        `json:"binary"`
+	Encodings      map[string]string   `json:"encodings"`
+	Limits         LimitsConfig        `json:"limits"`
+	Generated      GeneratedConfig     `json:"generated"`
+	Secrets        SecretsConfig       `json:"secrets"`
+	Strip          StripConfig         `json:"strip"`
+	Outline        OutlineConfig       `json:"outline"`
+	Order          OrderConfig         `json:"order"`
+	LineNumbers    LineNumbersConfig   `json:"line_numbers"`
+	Annotations    AnnotationsConfig   `json:"annotations"`
 }
 
 func NewConfig() *Config {
@@ -23,6 +36,8 @@ func NewConfig() *Config {
 		Include:        map[string]struct{}{},
 		SkipDirs:       map[string]bool{},
 		Languages:      map[string]string{},
+		GitAttributes:  map[string]string{},
+		Encodings:      map[string]string{},
 	}
 }
 
@@ -198,6 +213,71 @@ func getDefaultConfig() Config {
 			".rsync-filter":          {},
 			"LICENSE":                {},
 		},
+		Submodules: submodulesRecurse,
+		GitAttributes: map[string]string{
+			"binary":                 attrActionSkip,
+			"export-ignore":          attrActionSkip,
+			"linguist-documentation": attrActionIgnore,
+			"linguist-generated":     attrActionSkip,
+			"linguist-vendored":      attrActionSkip,
+		},
+		LFS: lfsAnnotate,
+		Binary: BinaryConfig{
+			Action:         binarySkip,
+			SniffBytes:     8192,
+			MaxInvalidUTF8: pointerTo(0.3),
+		},
+		Encodings: map[string]string{},
+		Limits: LimitsConfig{
+			Truncate:  truncateHeadMarker,
+			TailLines: 20,
+		},
+		Generated: GeneratedConfig{
+			Policies: map[string]string{
+				categoryGenerated: generatedStub,
+				categoryMinified:  generatedStub,
+			},
+			Patterns: map[string]string{
+				"*.bundle.js":    categoryMinified,
+				"*.designer.cs":  categoryGenerated,
+				"*.freezed.dart": categoryGenerated,
+				"*.g.dart":       categoryGenerated,
+				"*.generated.cs": categoryGenerated,
+				"*.generated.ts": categoryGenerated,
+				"*.min.css":      categoryMinified,
+				"*.min.js":       categoryMinified,
+				"*.pb.cc":        categoryGenerated,
+				"*.pb.go":        categoryGenerated,
+				"*.pb.gw.go":     categoryGenerated,
+				"*.pb.h":         categoryGenerated,
+				"*_generated.go": categoryGenerated,
+				"*_pb2.py":       categoryGenerated,
+				"*_pb2.pyi":      categoryGenerated,
+				"*_pb2_grpc.py":  categoryGenerated,
+			},
+			Markers: []string{
+				`^// Code generated .* DO NOT EDIT\.$`,
+				`^\s*(?://+|#+|/?\*+|--|<!--)\s*@generated(?:\s|$)`,
+				`^\s*//\s*<auto-generated`,
+			},
+			MarkerLines:       10,
+			MaxLineLength:     1000,
+			MaxAvgLineLength:  300,
+			MinMinifiedLength: 2048,
+		},
+		Secrets: SecretsConfig{
+			EntropyThreshold: 4.2,
+			EntropyMinLength: 24,
+		},
+		Strip: StripConfig{
+			MaxBlankLines: 1,
+		},
+		LineNumbers: LineNumbersConfig{
+			Separator: " | ",
+		},
+		Annotations: AnnotationsConfig{
+			Tags: []string{"TODO", "FIXME", "HACK", "XXX"},
+		},
 	}
 }
 
@@ -221,12 +301,53 @@ func loadCustomConfig(config Config, configPath string) (Config, error) {
 		return config, fmt.Errorf("failed to decode config: %w", err)
 	}
 
+	for attr, action := range customConfig.GitAttributes {
+		switch action {
+		case attrActionSkip, attrActionInclude, attrActionIgnore:
+		default:
+			return config, fmt.Errorf("unknown action %q for git attribute %s", action, attr)
+		}
+	}
+	for pattern, rule := range customConfig.Limits.Rules {
+		switch rule.Truncate {
+		case "", truncateSkip, truncateHead, truncateHeadTail, truncateHeadMarker:
+		default:
+			return config, fmt.Errorf("unknown truncation strategy %q for %s", rule.Truncate, pattern)
+		}
+	}
+	for extension, settings := range customConfig.Binary.Extensions {
+		switch settings.Action {
+		case "", binarySkip, binaryPlaceholder:
+		default:
+			return config, fmt.Errorf("unknown binary file action %q for %s", settings.Action, extension)
+		}
+	}
+
 	// Apply overrides
 	mergeMap(&config.CodeExtensions, customConfig.CodeExtensions)
 	mergeMap(&config.SkipDirs, customConfig.SkipDirs)
 	mergeMap(&config.Languages, customConfig.Languages)
 	mergeMap(&config.Exclude, customConfig.Exclude)
 	mergeMap(&config.Include, customConfig.Include)
+	mergeMap(&config.GitAttributes, customConfig.GitAttributes)
+	if customConfig.Submodules != "" {
+		config.Submodules = customConfig.Submodules
+	}
+	if customConfig.LFS != "" {
+		config.LFS = customConfig.LFS
+	}
+	mergeMap(&config.Encodings, customConfig.Encodings)
+	mergeMap(&config.Binary.Extensions, customConfig.Binary.Extensions)
+	config.Binary = config.Binary.override(customConfig.Binary)
+	mergeMap(&config.Limits.Rules, customConfig.Limits.Rules)
+	config.Limits = config.Limits.override(customConfig.Limits)
+	config.Generated = config.Generated.override(customConfig.Generated)
+	config.Secrets = config.Secrets.override(customConfig.Secrets)
+	config.Strip = config.Strip.override(customConfig.Strip)
+	config.Outline = config.Outline.override(customConfig.Outline)
+	config.Order = config.Order.override(customConfig.Order)
+	config.LineNumbers = config.LineNumbers.override(customConfig.LineNumbers)
+	config.Annotations = config.Annotations.override(customConfig.Annotations)
 
 	return config, nil
 }
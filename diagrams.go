@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Styles of packages and imports in a cycle
+const (
+	mermaidCycleClass = "classDef cycle fill:#fdd,stroke:#c00,stroke-width:2px"
+	mermaidCycleLink  = "stroke:#c00,stroke-width:2px"
+)
+
+// Quote a Mermaid node label, double quotes are written as entities
+func mermaidLabel(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, "#quot;") + `"`
+}
+
+// Render the directories of the files down to depth as a Mermaid graph, each with the number of files below it.
+// Paths are relative and use slashes.
+func formatDirectoryDiagram(rootName string, relPaths []string, depth int) string {
+	counts := map[string]int{".": len(relPaths)}
+	for _, relPath := range relPaths {
+		parts := strings.Split(path.Dir(relPath), "/")
+		if parts[0] == "." {
+			continue
+		}
+		for i := 1; i <= len(parts) && i <= depth; i++ {
+			counts[strings.Join(parts[:i], "/")]++
+		}
+	}
+
+	dirs := sortedKeys(counts)
+	ids := make(map[string]string, len(dirs))
+	var buf bytes.Buffer
+	buf.WriteString("```mermaid\ngraph LR\n")
+	for i, dir := range dirs {
+		ids[dir] = fmt.Sprintf("d%d", i)
+		name := path.Base(dir)
+		if dir == "." {
+			name = rootName
+		}
+		noun := "files"
+		if counts[dir] == 1 {
+			noun = "file"
+		}
+		fmt.Fprintf(&buf, "  %s[%s]\n", ids[dir], mermaidLabel(fmt.Sprintf("%s/ (%d %s)", name, counts[dir], noun)))
+	}
+	for _, dir := range dirs {
+		if dir != "." {
+			fmt.Fprintf(&buf, "  %s --> %s\n", ids[path.Dir(dir)], ids[dir])
+		}
+	}
+	buf.WriteString("```\n\n")
+	return buf.String()
+}
+
+// Render the imports between packages as a Mermaid graph. Packages and imports in cycles are highlighted
+// and every cycle is listed below the graph.
+func formatPackageDiagram(imports map[string][]string, packages []string, label func(dir string) string) string {
+	cycles := importCycles(imports, packages)
+	inCycle := make(map[string]int)
+	for i, cycle := range cycles {
+		for _, pkg := range cycle {
+			inCycle[pkg] = i + 1
+		}
+	}
+
+	ids := make(map[string]string, len(packages))
+	var buf bytes.Buffer
+	buf.WriteString("```mermaid\ngraph LR\n")
+	for i, pkg := range packages {
+		ids[pkg] = fmt.Sprintf("p%d", i)
+		fmt.Fprintf(&buf, "  %s[%s]\n", ids[pkg], mermaidLabel(label(pkg)))
+	}
+
+	var cycleLinks []string
+	link := 0
+	for _, pkg := range packages {
+		deps := append([]string{}, imports[pkg]...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if _, exists := ids[dep]; !exists {
+				continue
+			}
+			fmt.Fprintf(&buf, "  %s --> %s\n", ids[pkg], ids[dep])
+			if inCycle[pkg] != 0 && inCycle[pkg] == inCycle[dep] {
+				cycleLinks = append(cycleLinks, strconv.Itoa(link))
+			}
+			link++
+		}
+	}
+
+	if len(cycles) > 0 {
+		var nodes []string
+		for _, pkg := range packages {
+			if inCycle[pkg] != 0 {
+				nodes = append(nodes, ids[pkg])
+			}
+		}
+		fmt.Fprintf(&buf, "  %s\n  class %s cycle\n", mermaidCycleClass, strings.Join(nodes, ","))
+		fmt.Fprintf(&buf, "  linkStyle %s %s\n", strings.Join(cycleLinks, ","), mermaidCycleLink)
+	}
+	buf.WriteString("```\n\n")
+
+	for _, cycle := range cycles {
+		labels := make([]string, len(cycle))
+		for i, pkg := range cycle {
+			labels[i] = "`" + label(pkg) + "`"
+		}
+		fmt.Fprintf(&buf, "**Import cycle**: %s\n\n", strings.Join(labels, ", "))
+	}
+	return buf.String()
+}
+
+// Find groups of nodes importing each other, directly or through other nodes (strongly connected components
+// with more than one node). Nodes of a group and the groups are in the order of nodes.
+func importCycles(edges map[string][]string, nodes []string) [][]string {
+	index := make(map[string]int)
+	lowLink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var components []map[string]bool
+
+	var connect func(node string)
+	connect = func(node string) {
+		index[node] = len(index) + 1
+		lowLink[node] = index[node]
+		stack = append(stack, node)
+		onStack[node] = true
+
+		for _, dep := range edges[node] {
+			if index[dep] == 0 {
+				connect(dep)
+				lowLink[node] = min(lowLink[node], lowLink[dep])
+			} else if onStack[dep] {
+				lowLink[node] = min(lowLink[node], index[dep])
+			}
+		}
+
+		if lowLink[node] != index[node] {
+			return
+		}
+		component := make(map[string]bool)
+		for {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[last] = false
+			component[last] = true
+			if last == node {
+				break
+			}
+		}
+		if len(component) > 1 {
+			components = append(components, component)
+		}
+	}
+
+	for _, node := range nodes {
+		if index[node] == 0 {
+			connect(node)
+		}
+	}
+
+	cycles := make([][]string, len(components))
+	for i, component := range components {
+		for _, node := range nodes {
+			if component[node] {
+				cycles[i] = append(cycles[i], node)
+			}
+		}
+	}
+	sort.SliceStable(cycles, func(i, j int) bool {
+		return strings.Join(cycles[i], "\x00") < strings.Join(cycles[j], "\x00")
+	})
+	return cycles
+}
@@ -0,0 +1,72 @@
+package main
+
+import "regexp"
+
+// Inline directives, written in a comment as "project2md:<name>"
+const (
+	directiveIgnoreFile  = "ignore-file"
+	directiveIgnoreStart = "ignore-start"
+	directiveIgnoreEnd   = "ignore-end"
+	directiveOnlyStart   = "only-start"
+	directiveOnlyEnd     = "only-end"
+)
+
+// A directive after the comment marker of any common syntax: //, #, --, ;, %, ', /*, <!--, {-, (* and REM
+var directiveRegexp = regexp.MustCompile(`(?:^|\s)(?://+|#+|--+|;+|%+|'|/\*+|<!--|\{-|\(\*|(?i:rem))\s*project2md:(` +
+	directiveIgnoreFile + `|` + directiveIgnoreStart + `|` + directiveIgnoreEnd + `|` +
+	directiveOnlyStart + `|` + directiveOnlyEnd + `)\b`)
+
+// Name of the directive on a line, empty if there is none
+func lineDirective(text string) string {
+	if match := directiveRegexp.FindStringSubmatch(text); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// Remove the regions excluded by directives, replacing every run of removed lines with a marker.
+// Lines between ignore-start and ignore-end are removed; if there are only-start and only-end
+// regions, everything outside them is removed as well. Lines holding directives are always removed.
+// Returns ignoreFile if the file has an ignore-file directive.
+func filterDirectiveRegions(lines []SourceLine) (result []SourceLine, removed int, ignoreFile bool) {
+	hasOnly := false
+	for _, line := range lines {
+		switch lineDirective(line.Text) {
+		case directiveIgnoreFile:
+			return nil, 0, true
+		case directiveOnlyStart:
+			hasOnly = true
+		}
+	}
+
+	inIgnore, inOnly := false, false
+	pending := 0
+	flush := func() {
+		if pending > 0 {
+			result = append(result, omissionMarker(pending, "excluded by directive"))
+			removed += pending
+			pending = 0
+		}
+	}
+	for _, line := range lines {
+		switch lineDirective(line.Text) {
+		case directiveIgnoreStart:
+			inIgnore = true
+		case directiveIgnoreEnd:
+			inIgnore = false
+		case directiveOnlyStart:
+			inOnly = true
+		case directiveOnlyEnd:
+			inOnly = false
+		default:
+			if !inIgnore && (inOnly || !hasOnly) {
+				flush()
+				result = append(result, line)
+				continue
+			}
+		}
+		pending++
+	}
+	flush()
+	return result, removed, false
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Names of supported text encodings
+const (
+	encodingUTF8        = "UTF-8"
+	encodingUTF8BOM     = "UTF-8 with BOM"
+	encodingUTF16LE     = "UTF-16LE"
+	encodingUTF16BE     = "UTF-16BE"
+	encodingUTF32LE     = "UTF-32LE"
+	encodingUTF32BE     = "UTF-32BE"
+	encodingWindows1251 = "windows-1251"
+	encodingWindows1252 = "windows-1252"
+	encodingKOI8R       = "koi8-r"
+	encodingCP866       = "cp866"
+	encodingLatin1      = "iso-8859-1"
+)
+
+// Byte order marks, longer ones first
+var byteOrderMarks = []struct {
+	bom      []byte
+	encoding string
+}{
+	{[]byte{0xFF, 0xFE, 0x00, 0x00}, encodingUTF32LE},
+	{[]byte{0x00, 0x00, 0xFE, 0xFF}, encodingUTF32BE},
+	{[]byte{0xEF, 0xBB, 0xBF}, encodingUTF8BOM},
+	{[]byte{0xFF, 0xFE}, encodingUTF16LE},
+	{[]byte{0xFE, 0xFF}, encodingUTF16BE},
+}
+
+// Single-byte encodings tried when content is not valid UTF-8
+var cyrillicEncodings = []string{encodingWindows1251, encodingKOI8R, encodingCP866}
+
+// Normalize a user supplied encoding name, empty if it is not supported
+func normalizeEncoding(name string) string {
+	key := strings.NewReplacer("-", "", "_", "", " ", "").Replace(strings.ToLower(name))
+	switch key {
+	case "utf8":
+		return encodingUTF8
+	case "utf8bom", "utf8withbom":
+		return encodingUTF8BOM
+	case "utf16", "utf16le":
+		return encodingUTF16LE
+	case "utf16be":
+		return encodingUTF16BE
+	case "utf32", "utf32le":
+		return encodingUTF32LE
+	case "utf32be":
+		return encodingUTF32BE
+	case "windows1251", "cp1251":
+		return encodingWindows1251
+	case "windows1252", "cp1252":
+		return encodingWindows1252
+	case "koi8r":
+		return encodingKOI8R
+	case "cp866", "ibm866", "dos866":
+		return encodingCP866
+	case "iso88591", "latin1":
+		return encodingLatin1
+	}
+	return ""
+}
+
+// Guess the encoding of content, empty if it does not look like text in a known encoding
+func detectEncoding(content []byte) string {
+	for _, mark := range byteOrderMarks {
+		if bytes.HasPrefix(content, mark.bom) {
+			return mark.encoding
+		}
+	}
+
+	// NUL bytes are valid UTF-8, so check UTF-16 first
+	if encoding := detectUTF16(content); encoding != "" {
+		return encoding
+	}
+	if utf8.Valid(content) {
+		return encodingUTF8
+	}
+
+	// Legacy encodings never contain control characters
+	for _, b := range content {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != 0x1B {
+			return ""
+		}
+	}
+
+	// Words of Cyrillic text are runs of high bytes, while accented
+	// Latin letters are mostly surrounded by ASCII ones
+	high, paired := 0, 0
+	for i, b := range content {
+		if b < 0x80 {
+			continue
+		}
+		high++
+		if (i > 0 && content[i-1] >= 0x80) || (i+1 < len(content) && content[i+1] >= 0x80) {
+			paired++
+		}
+	}
+	if paired*2 < high {
+		return encodingWindows1252
+	}
+
+	best, bestScore := "", 0
+	for _, encoding := range cyrillicEncodings {
+		if score := cyrillicScore(content, singleByteTables[encoding]); best == "" || score > bestScore {
+			best, bestScore = encoding, score
+		}
+	}
+	return best
+}
+
+// Recognize UTF-16 without BOM by NUL bytes in every other position
+func detectUTF16(content []byte) string {
+	const minSample = 4
+	if len(content) < minSample || len(content)%2 != 0 {
+		return ""
+	}
+
+	evenZeros, oddZeros := 0, 0
+	for i := 0; i < len(content); i += 2 {
+		if content[i] == 0 {
+			evenZeros++
+		}
+		if content[i+1] == 0 {
+			oddZeros++
+		}
+	}
+	pairs := len(content) / 2
+	encoding := ""
+	switch {
+	case oddZeros*2 > pairs && evenZeros*10 < pairs:
+		encoding = encodingUTF16LE
+	case evenZeros*2 > pairs && oddZeros*10 < pairs:
+		encoding = encodingUTF16BE
+	default:
+		return ""
+	}
+
+	// Binary data with many zeros decodes to control characters
+	for _, r := range string(decodeUTF16(content, encoding == encodingUTF16BE)) {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' && r != '\f' {
+			return ""
+		}
+	}
+	return encoding
+}
+
+// Rate how natural the text looks when decoded with table:
+// Cyrillic text is mostly lowercase letters, pseudographics are unlikely
+func cyrillicScore(content []byte, table *[128]rune) int {
+	score := 0
+	for _, b := range content {
+		if b < 0x80 {
+			continue
+		}
+		r := table[b-0x80]
+		switch {
+		case unicode.Is(unicode.Cyrillic, r) && unicode.IsLower(r):
+			score += 2
+		case unicode.Is(unicode.Cyrillic, r):
+			score++
+		default:
+			score -= 2
+		}
+	}
+	return score
+}
+
+// Convert content in the given encoding to UTF-8 without BOM
+func decodeToUTF8(content []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case encodingUTF8:
+		return content, nil
+	case encodingUTF8BOM:
+		return bytes.TrimPrefix(content, []byte{0xEF, 0xBB, 0xBF}), nil
+	case encodingUTF16LE, encodingUTF16BE:
+		return decodeUTF16(content, encoding == encodingUTF16BE), nil
+	case encodingUTF32LE, encodingUTF32BE:
+		return decodeUTF32(content, encoding == encodingUTF32BE), nil
+	}
+
+	table, exists := singleByteTables[encoding]
+	if !exists {
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+	result := make([]byte, 0, len(content))
+	for _, b := range content {
+		if b < 0x80 {
+			result = append(result, b)
+		} else {
+			result = utf8.AppendRune(result, table[b-0x80])
+		}
+	}
+	return result, nil
+}
+
+func decodeUTF16(content []byte, bigEndian bool) []byte {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+
+	units := make([]uint16, 0, len(content)/2)
+	for i := 0; i+1 < len(content); i += 2 {
+		units = append(units, order.Uint16(content[i:]))
+	}
+	if len(units) > 0 && units[0] == 0xFEFF {
+		units = units[1:]
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+func decodeUTF32(content []byte, bigEndian bool) []byte {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+
+	result := make([]byte, 0, len(content))
+	for i := 0; i+3 < len(content); i += 4 {
+		r := rune(order.Uint32(content[i:]))
+		if i == 0 && r == 0xFEFF {
+			continue
+		}
+		result = utf8.AppendRune(result, r)
+	}
+	return result
+}
+
+// Upper halves (0x80-0xFF) of the supported single-byte encodings
+var singleByteTables = map[string]*[128]rune{
+	encodingWindows1251: &windows1251Table,
+	encodingWindows1252: &windows1252Table,
+	encodingKOI8R:       &koi8rTable,
+	encodingCP866:       &cp866Table,
+	encodingLatin1:      &latin1Table,
+}
+
+var latin1Table = func() (table [128]rune) {
+	for i := range table {
+		table[i] = rune(0x80 + i)
+	}
+	return table
+}()
+
+var windows1251Table = [128]rune{
+	0x0402, 0x0403, 0x201A, 0x0453, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x20AC, 0x2030, 0x0409, 0x2039, 0x040A, 0x040C, 0x040B, 0x040F,
+	0x0452, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x0098, 0x2122, 0x0459, 0x203A, 0x045A, 0x045C, 0x045B, 0x045F,
+	0x00A0, 0x040E, 0x045E, 0x0408, 0x00A4, 0x0490, 0x00A6, 0x00A7,
+	0x0401, 0x00A9, 0x0404, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x0407,
+	0x00B0, 0x00B1, 0x0406, 0x0456, 0x0491, 0x00B5, 0x00B6, 0x00B7,
+	0x0451, 0x2116, 0x0454, 0x00BB, 0x0458, 0x0405, 0x0455, 0x0457,
+	0x0410, 0x0411, 0x0412, 0x0413, 0x0414, 0x0415, 0x0416, 0x0417,
+	0x0418, 0x0419, 0x041A, 0x041B, 0x041C, 0x041D, 0x041E, 0x041F,
+	0x0420, 0x0421, 0x0422, 0x0423, 0x0424, 0x0425, 0x0426, 0x0427,
+	0x0428, 0x0429, 0x042A, 0x042B, 0x042C, 0x042D, 0x042E, 0x042F,
+	0x0430, 0x0431, 0x0432, 0x0433, 0x0434, 0x0435, 0x0436, 0x0437,
+	0x0438, 0x0439, 0x043A, 0x043B, 0x043C, 0x043D, 0x043E, 0x043F,
+	0x0440, 0x0441, 0x0442, 0x0443, 0x0444, 0x0445, 0x0446, 0x0447,
+	0x0448, 0x0449, 0x044A, 0x044B, 0x044C, 0x044D, 0x044E, 0x044F,
+}
+
+var windows1252Table = [128]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+	0x00A0, 0x00A1, 0x00A2, 0x00A3, 0x00A4, 0x00A5, 0x00A6, 0x00A7,
+	0x00A8, 0x00A9, 0x00AA, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x00AF,
+	0x00B0, 0x00B1, 0x00B2, 0x00B3, 0x00B4, 0x00B5, 0x00B6, 0x00B7,
+	0x00B8, 0x00B9, 0x00BA, 0x00BB, 0x00BC, 0x00BD, 0x00BE, 0x00BF,
+	0x00C0, 0x00C1, 0x00C2, 0x00C3, 0x00C4, 0x00C5, 0x00C6, 0x00C7,
+	0x00C8, 0x00C9, 0x00CA, 0x00CB, 0x00CC, 0x00CD, 0x00CE, 0x00CF,
+	0x00D0, 0x00D1, 0x00D2, 0x00D3, 0x00D4, 0x00D5, 0x00D6, 0x00D7,
+	0x00D8, 0x00D9, 0x00DA, 0x00DB, 0x00DC, 0x00DD, 0x00DE, 0x00DF,
+	0x00E0, 0x00E1, 0x00E2, 0x00E3, 0x00E4, 0x00E5, 0x00E6, 0x00E7,
+	0x00E8, 0x00E9, 0x00EA, 0x00EB, 0x00EC, 0x00ED, 0x00EE, 0x00EF,
+	0x00F0, 0x00F1, 0x00F2, 0x00F3, 0x00F4, 0x00F5, 0x00F6, 0x00F7,
+	0x00F8, 0x00F9, 0x00FA, 0x00FB, 0x00FC, 0x00FD, 0x00FE, 0x00FF,
+}
+
+var koi8rTable = [128]rune{
+	0x2500, 0x2502, 0x250C, 0x2510, 0x2514, 0x2518, 0x251C, 0x2524,
+	0x252C, 0x2534, 0x253C, 0x2580, 0x2584, 0x2588, 0x258C, 0x2590,
+	0x2591, 0x2592, 0x2593, 0x2320, 0x25A0, 0x2219, 0x221A, 0x2248,
+	0x2264, 0x2265, 0x00A0, 0x2321, 0x00B0, 0x00B2, 0x00B7, 0x00F7,
+	0x2550, 0x2551, 0x2552, 0x0451, 0x2553, 0x2554, 0x2555, 0x2556,
+	0x2557, 0x2558, 0x2559, 0x255A, 0x255B, 0x255C, 0x255D, 0x255E,
+	0x255F, 0x2560, 0x2561, 0x0401, 0x2562, 0x2563, 0x2564, 0x2565,
+	0x2566, 0x2567, 0x2568, 0x2569, 0x256A, 0x256B, 0x256C, 0x00A9,
+	0x044E, 0x0430, 0x0431, 0x0446, 0x0434, 0x0435, 0x0444, 0x0433,
+	0x0445, 0x0438, 0x0439, 0x043A, 0x043B, 0x043C, 0x043D, 0x043E,
+	0x043F, 0x044F, 0x0440, 0x0441, 0x0442, 0x0443, 0x0436, 0x0432,
+	0x044C, 0x044B, 0x0437, 0x0448, 0x044D, 0x0449, 0x0447, 0x044A,
+	0x042E, 0x0410, 0x0411, 0x0426, 0x0414, 0x0415, 0x0424, 0x0413,
+	0x0425, 0x0418, 0x0419, 0x041A, 0x041B, 0x041C, 0x041D, 0x041E,
+	0x041F, 0x042F, 0x0420, 0x0421, 0x0422, 0x0423, 0x0416, 0x0412,
+	0x042C, 0x042B, 0x0417, 0x0428, 0x042D, 0x0429, 0x0427, 0x042A,
+}
+
+var cp866Table = [128]rune{
+	0x0410, 0x0411, 0x0412, 0x0413, 0x0414, 0x0415, 0x0416, 0x0417,
+	0x0418, 0x0419, 0x041A, 0x041B, 0x041C, 0x041D, 0x041E, 0x041F,
+	0x0420, 0x0421, 0x0422, 0x0423, 0x0424, 0x0425, 0x0426, 0x0427,
+	0x0428, 0x0429, 0x042A, 0x042B, 0x042C, 0x042D, 0x042E, 0x042F,
+	0x0430, 0x0431, 0x0432, 0x0433, 0x0434, 0x0435, 0x0436, 0x0437,
+	0x0438, 0x0439, 0x043A, 0x043B, 0x043C, 0x043D, 0x043E, 0x043F,
+	0x2591, 0x2592, 0x2593, 0x2502, 0x2524, 0x2561, 0x2562, 0x2556,
+	0x2555, 0x2563, 0x2551, 0x2557, 0x255D, 0x255C, 0x255B, 0x2510,
+	0x2514, 0x2534, 0x252C, 0x251C, 0x2500, 0x253C, 0x255E, 0x255F,
+	0x255A, 0x2554, 0x2569, 0x2566, 0x2560, 0x2550, 0x256C, 0x2567,
+	0x2568, 0x2564, 0x2565, 0x2559, 0x2558, 0x2552, 0x2553, 0x256B,
+	0x256A, 0x2518, 0x250C, 0x2588, 0x2584, 0x258C, 0x2590, 0x2580,
+	0x0440, 0x0441, 0x0442, 0x0443, 0x0444, 0x0445, 0x0446, 0x0447,
+	0x0448, 0x0449, 0x044A, 0x044B, 0x044C, 0x044D, 0x044E, 0x044F,
+	0x0401, 0x0451, 0x0404, 0x0454, 0x0407, 0x0457, 0x040E, 0x045E,
+	0x00B0, 0x2219, 0x00B7, 0x221A, 0x2116, 0x00A4, 0x25A0, 0x00A0,
+}
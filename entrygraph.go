@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Extensions of files whose imports are followed
+var (
+	jsExtensions     = []string{".ts", ".tsx", ".mts", ".cts", ".js", ".jsx", ".mjs", ".cjs"}
+	pythonExtensions = []string{".py", ".pyi"}
+)
+
+// Import statements of JavaScript and TypeScript
+var (
+	jsImportRegexp  = regexp.MustCompile(`(?m)^\s*(?:import|export)\s+(?:type\s+)?(?:[\w*{}\s,$]+?\s+from\s+)?["']([^"']+)["']`)
+	jsRequireRegexp = regexp.MustCompile(`\b(?:require|import)\s*\(\s*["']([^"']+)["']\s*\)`)
+)
+
+// Import statements of Python
+var (
+	pyImportRegexp     = regexp.MustCompile(`(?m)^[ \t]*import[ \t]+([\w., \t]+)`)
+	pyFromImportRegexp = regexp.MustCompile(`(?m)^[ \t]*from[ \t]+(\.*)([\w.]*)[ \t]+import[ \t]+(\([^)]*\)|[^\n]*)`)
+)
+
+// tsConfig holds module resolution settings of tsconfig.json or jsconfig.json
+type tsConfig struct {
+	Extends         string `json:"extends"`
+	CompilerOptions struct {
+		BaseURL string              `json:"baseUrl"`
+		Paths   map[string][]string `json:"paths"`
+	} `json:"compilerOptions"`
+	// Directory baseUrl and paths are relative to
+	dir string
+}
+
+// ImportGraph follows relative imports of JavaScript, TypeScript and Python files within a project
+type ImportGraph struct {
+	root    string
+	imports map[string][]string
+	// tsconfig.json found for directories, nil if there is none
+	tsConfigs map[string]*tsConfig
+}
+
+// Collect files reachable by imports from the entry files
+func loadImportGraph(entries []string, root string) (*ImportGraph, error) {
+	graph := &ImportGraph{
+		root:      root,
+		imports:   make(map[string][]string),
+		tsConfigs: make(map[string]*tsConfig),
+	}
+
+	queue := append([]string{}, entries...)
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		if _, visited := graph.imports[path]; visited {
+			continue
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		var deps []string
+		ext := strings.ToLower(filepath.Ext(path))
+		switch {
+		case containsString(jsExtensions, ext):
+			deps = graph.jsImports(path, string(content))
+		case containsString(pythonExtensions, ext):
+			deps = graph.pythonImports(path, string(content))
+		}
+		graph.imports[path] = deps
+		queue = append(queue, deps...)
+	}
+	return graph, nil
+}
+
+// Reachable files with imported files before the files importing them
+func (g *ImportGraph) fileOrder() []string {
+	return dependencyOrder(g.imports, sortedKeys(g.imports))
+}
+
+// Resolve imports of a JavaScript or TypeScript file to files of the project
+func (g *ImportGraph) jsImports(path, content string) []string {
+	var specifiers []string
+	for _, re := range []*regexp.Regexp{jsImportRegexp, jsRequireRegexp} {
+		for _, match := range re.FindAllStringSubmatch(content, -1) {
+			specifiers = append(specifiers, match[1])
+		}
+	}
+
+	var deps []string
+	for _, specifier := range specifiers {
+		if dep := g.resolveJS(filepath.Dir(path), specifier); dep != "" && !containsString(deps, dep) {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+// Resolve a module specifier: relative paths, tsconfig paths and baseUrl. Packages are not followed.
+func (g *ImportGraph) resolveJS(dir, specifier string) string {
+	if strings.HasPrefix(specifier, "./") || strings.HasPrefix(specifier, "../") || specifier == "." || specifier == ".." {
+		return g.jsFile(filepath.Join(dir, filepath.FromSlash(specifier)))
+	}
+
+	config := g.tsConfigFor(dir)
+	if config == nil {
+		return ""
+	}
+	baseDir := filepath.Join(config.dir, filepath.FromSlash(config.CompilerOptions.BaseURL))
+
+	// Exact aliases first, then wildcard aliases with the longest prefix
+	patterns := sortedKeys(config.CompilerOptions.Paths)
+	sort.SliceStable(patterns, func(i, j int) bool {
+		return len(strings.Split(patterns[i], "*")[0]) > len(strings.Split(patterns[j], "*")[0])
+	})
+	for _, pattern := range patterns {
+		prefix, suffix, wildcard := strings.Cut(pattern, "*")
+		var matched string
+		switch {
+		case !wildcard && specifier == pattern:
+		case wildcard && strings.HasPrefix(specifier, prefix) && strings.HasSuffix(specifier, suffix) &&
+			len(specifier) >= len(prefix)+len(suffix):
+			matched = specifier[len(prefix) : len(specifier)-len(suffix)]
+		default:
+			continue
+		}
+		for _, target := range config.CompilerOptions.Paths[pattern] {
+			target = strings.Replace(target, "*", matched, 1)
+			if file := g.jsFile(filepath.Join(baseDir, filepath.FromSlash(target))); file != "" {
+				return file
+			}
+		}
+	}
+
+	if config.CompilerOptions.BaseURL != "" {
+		return g.jsFile(filepath.Join(baseDir, filepath.FromSlash(specifier)))
+	}
+	return ""
+}
+
+// Find the file a module path refers to: the file itself, with an extension added, or an index file
+func (g *ImportGraph) jsFile(path string) string {
+	if !isSubPath(g.root, path) {
+		return ""
+	}
+
+	candidates := []string{path}
+	// TypeScript sources are imported with the extension of the compiled file
+	ext := filepath.Ext(path)
+	if ext == ".js" || ext == ".jsx" || ext == ".mjs" || ext == ".cjs" {
+		base := strings.TrimSuffix(path, ext)
+		for _, tsExt := range []string{".ts", ".tsx", ".mts", ".cts"} {
+			candidates = append(candidates, base+tsExt)
+		}
+	}
+	for _, jsExt := range append(append([]string{}, jsExtensions...), ".json") {
+		candidates = append(candidates, path+jsExt)
+	}
+	for _, jsExt := range jsExtensions {
+		candidates = append(candidates, filepath.Join(path, "index"+jsExt))
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// Find the nearest tsconfig.json or jsconfig.json for the directory
+func (g *ImportGraph) tsConfigFor(dir string) *tsConfig {
+	if config, exists := g.tsConfigs[dir]; exists {
+		return config
+	}
+
+	var config *tsConfig
+	for _, name := range []string{"tsconfig.json", "jsconfig.json"} {
+		if loaded, err := loadTSConfig(filepath.Join(dir, name), 0); err == nil {
+			config = loaded
+			break
+		}
+	}
+	if config == nil && dir != g.root && isSubPath(g.root, dir) {
+		config = g.tsConfigFor(filepath.Dir(dir))
+	}
+	g.tsConfigs[dir] = config
+	return config
+}
+
+// Trailing commas allowed in tsconfig.json
+var trailingCommaRegexp = regexp.MustCompile(`,(\s*[}\]])`)
+
+// Load a tsconfig.json with comments and trailing commas, following relative extends
+func loadTSConfig(path string, depth int) (*tsConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove comments, keeping strings like "@/*" intact
+	text := string(content)
+	comments, _ := scanComments(text, commentSyntax{
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		strings:       []string{`"`},
+	})
+	for i := len(comments) - 1; i >= 0; i-- {
+		text = text[:comments[i].start] + text[comments[i].end:]
+	}
+	text = trailingCommaRegexp.ReplaceAllString(text, "$1")
+
+	config := &tsConfig{dir: filepath.Dir(path)}
+	if err := json.Unmarshal([]byte(text), config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	const maxExtendsDepth = 10
+	if config.Extends != "" && strings.HasPrefix(config.Extends, ".") && depth < maxExtendsDepth {
+		parentPath := filepath.Join(config.dir, filepath.FromSlash(config.Extends))
+		if filepath.Ext(parentPath) != ".json" {
+			parentPath += ".json"
+		}
+		if parent, err := loadTSConfig(parentPath, depth+1); err == nil {
+			if config.CompilerOptions.BaseURL == "" && parent.CompilerOptions.BaseURL != "" {
+				config.CompilerOptions.BaseURL = parent.CompilerOptions.BaseURL
+				config.dir = parent.dir
+			}
+			if config.CompilerOptions.Paths == nil {
+				config.CompilerOptions.Paths = parent.CompilerOptions.Paths
+			}
+		}
+	}
+	return config, nil
+}
+
+// Resolve imports of a Python file to modules of the project
+func (g *ImportGraph) pythonImports(path, content string) []string {
+	var deps []string
+	add := func(files []string) {
+		for _, file := range files {
+			if !containsString(deps, file) {
+				deps = append(deps, file)
+			}
+		}
+	}
+
+	for _, match := range pyImportRegexp.FindAllStringSubmatch(content, -1) {
+		for _, name := range strings.Split(match[1], ",") {
+			if fields := strings.Fields(name); len(fields) > 0 {
+				files, _ := g.pythonModule(path, "", fields[0])
+				add(files)
+			}
+		}
+	}
+
+	for _, match := range pyFromImportRegexp.FindAllStringSubmatch(content, -1) {
+		dots, module := match[1], match[2]
+		files, found := g.pythonModule(path, dots, module)
+		if !found {
+			continue
+		}
+		add(files)
+
+		// Imported names may be submodules
+		names := strings.Trim(strings.TrimSpace(match[3]), "()")
+		for _, name := range strings.Split(names, ",") {
+			fields := strings.Fields(strings.SplitN(name, "#", 2)[0])
+			if len(fields) == 0 || fields[0] == "*" {
+				continue
+			}
+			submodule := fields[0]
+			if module != "" {
+				submodule = module + "." + fields[0]
+			}
+			submoduleFiles, _ := g.pythonModule(path, dots, submodule)
+			add(submoduleFiles)
+		}
+	}
+	return deps
+}
+
+// Files of a module and its parent packages. Relative modules start at the importing file,
+// absolute ones are looked up in the directory of the importing file, the project root and src.
+func (g *ImportGraph) pythonModule(importer, dots, module string) (files []string, found bool) {
+	var bases []string
+	if dots != "" {
+		base := filepath.Dir(importer)
+		for i := 1; i < len(dots); i++ {
+			base = filepath.Dir(base)
+		}
+		bases = []string{base}
+	} else {
+		bases = []string{filepath.Dir(importer), g.root, filepath.Join(g.root, "src")}
+	}
+
+	var parts []string
+	if module != "" {
+		parts = strings.Split(module, ".")
+	}
+	for _, base := range bases {
+		if base != g.root && !isSubPath(g.root, base) {
+			continue
+		}
+		if files, found := pythonModuleFiles(base, parts); found {
+			return files, true
+		}
+	}
+	return nil, false
+}
+
+// Find the module below base: __init__.py of every package on the way and the module file.
+// Namespace packages are found without files.
+func pythonModuleFiles(base string, parts []string) (files []string, found bool) {
+	dir := base
+	for i, part := range parts {
+		dir = filepath.Join(dir, part)
+		if i == len(parts)-1 {
+			if file := existingFile(dir+".py", dir+".pyi"); file != "" {
+				return append(files, file), true
+			}
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return nil, false
+		}
+		if init := existingFile(filepath.Join(dir, "__init__.py")); init != "" {
+			files = append(files, init)
+		}
+	}
+	if len(parts) == 0 {
+		if init := existingFile(filepath.Join(base, "__init__.py")); init != "" {
+			files = append(files, init)
+		}
+	}
+	return files, true
+}
+
+// First of the paths that is an existing file
+func existingFile(paths ...string) string {
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Categories of machine-made files
+const (
+	categoryGenerated = "generated"
+	categoryMinified  = "minified"
+)
+
+// Policies for generated and minified files
+const (
+	generatedSkip    = "skip"
+	generatedStub    = "stub"
+	generatedInclude = "include"
+)
+
+// GeneratedConfig controls detection of generated and minified files.
+// Patterns map file globs to a category, markers are regular expressions matched against each of the first lines of a file.
+type GeneratedConfig struct {
+	Policies          map[string]string `json:"policies"`
+	Patterns          map[string]string `json:"patterns"`
+	Markers           []string          `json:"markers,omitempty"`
+	MarkerLines       int               `json:"marker_lines,omitempty"`
+	MaxLineLength     int               `json:"max_line_length,omitempty"`
+	MaxAvgLineLength  int               `json:"max_avg_line_length,omitempty"`
+	MinMinifiedLength int               `json:"min_minified_length,omitempty"`
+}
+
+// Return a copy of the settings with values of other applied
+func (g GeneratedConfig) override(other GeneratedConfig) GeneratedConfig {
+	g.Policies = mergedMap(g.Policies, other.Policies)
+	g.Patterns = mergedMap(g.Patterns, other.Patterns)
+	if len(other.Markers) > 0 {
+		g.Markers = other.Markers
+	}
+	if other.MarkerLines > 0 {
+		g.MarkerLines = other.MarkerLines
+	}
+	if other.MaxLineLength > 0 {
+		g.MaxLineLength = other.MaxLineLength
+	}
+	if other.MaxAvgLineLength > 0 {
+		g.MaxAvgLineLength = other.MaxAvgLineLength
+	}
+	if other.MinMinifiedLength > 0 {
+		g.MinMinifiedLength = other.MinMinifiedLength
+	}
+	return g
+}
+
+// Compile the markers of generated files
+func generatedMarkerRegexps(markers []string) ([]*regexp.Regexp, error) {
+	regexps := make([]*regexp.Regexp, 0, len(markers))
+	for _, marker := range markers {
+		re, err := regexp.Compile(marker)
+		if err != nil {
+			return nil, fmt.Errorf("invalid generated file marker %q: %w", marker, err)
+		}
+		regexps = append(regexps, re)
+	}
+	return regexps, nil
+}
+
+// Detect whether the file is generated or minified. Returns the category and the reason.
+func detectGenerated(relPath string, lines []SourceLine, settings GeneratedConfig, markers []*regexp.Regexp) (category, reason string) {
+	// Longer patterns are more specific
+	var patterns []string
+	for pattern := range settings.Patterns {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		return len(patterns[i]) > len(patterns[j])
+	})
+	for _, pattern := range patterns {
+		if matchPathGlob(pattern, relPath) {
+			return settings.Patterns[pattern], "name matches " + pattern
+		}
+	}
+
+	for i, line := range lines {
+		if settings.MarkerLines > 0 && i >= settings.MarkerLines {
+			break
+		}
+		text := strings.TrimSuffix(line.Text, "\r")
+		for _, marker := range markers {
+			if match := marker.FindString(text); match != "" {
+				return categoryGenerated, fmt.Sprintf("marker %q", strings.TrimSpace(match))
+			}
+		}
+	}
+
+	size := linesSize(lines)
+	if len(lines) == 0 || size < int64(settings.MinMinifiedLength) {
+		return "", ""
+	}
+	if settings.MaxLineLength > 0 {
+		for _, line := range lines {
+			if len(strings.TrimSuffix(line.Text, "\r")) > settings.MaxLineLength {
+				return categoryMinified, fmt.Sprintf("line %d is longer than %d characters", line.Number, settings.MaxLineLength)
+			}
+		}
+	}
+	if settings.MaxAvgLineLength > 0 && size/int64(len(lines)) > int64(settings.MaxAvgLineLength) {
+		return categoryMinified, fmt.Sprintf("average line is longer than %d characters", settings.MaxAvgLineLength)
+	}
+	return "", ""
+}
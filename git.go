@@ -10,15 +10,20 @@ import (
 
 // GitIgnore represents a .gitignore parser
 type GitIgnore struct {
-	patterns []GitIgnorePattern
+	patterns  []GitIgnorePattern
+	repoRoots repoRoots
 }
 
+// repoRoots lists roots of git repositories found in the project
+type repoRoots []string
+
 // GitIgnorePattern represents a single .gitignore pattern
 type GitIgnorePattern struct {
 	pattern   string
 	isNegated bool
 	isDir     bool
 	basePath  string
+	repoRoot  string
 }
 
 // Parse .gitignore patterns
@@ -60,6 +65,15 @@ func loadGitIgnore(rootPath string) (*GitIgnore, error) {
 			return nil
 		}
 
+		// Remember repository roots to keep rules inside their own repository
+		if info.Name() == ".git" {
+			gi.repoRoots = append(gi.repoRoots, filepath.Dir(path))
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		if info.Name() == ".gitignore" {
 			file, err := os.Open(path)
 			if err != nil {
@@ -83,14 +97,33 @@ func loadGitIgnore(rootPath string) (*GitIgnore, error) {
 		return nil, fmt.Errorf("error walking directory: %w", err)
 	}
 
+	for i := range gi.patterns {
+		gi.patterns[i].repoRoot = gi.repoRoots.rootFor(gi.patterns[i].basePath, true)
+	}
+
 	return gi, nil
 }
 
+// Find the innermost repository containing path.
+// A repository root itself belongs to its parent repository unless isOwnRoot is set.
+func (roots repoRoots) rootFor(path string, isOwnRoot bool) string {
+	result := ""
+	for _, root := range roots {
+		if len(root) <= len(result) {
+			continue
+		}
+		if (isOwnRoot && path == root) || isSubPath(root, path) {
+			result = root
+		}
+	}
+	return result
+}
+
 // Check if path matches a gitignore pattern
 func (gi *GitIgnore) matchesPattern(path string, isDir bool, pattern GitIgnorePattern) bool {
 	// Get relative path from pattern's base directory
 	relPath, err := filepath.Rel(pattern.basePath, path)
-	if err != nil {
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
 		return false
 	}
 
@@ -135,9 +168,14 @@ func (gi *GitIgnore) matchesPattern(path string, isDir bool, pattern GitIgnorePa
 // Check if path should be ignored by .gitignore
 func (gi *GitIgnore) isMatchPattern(path string, isDir bool) bool {
 	result := false
+	repoRoot := gi.repoRoots.rootFor(path, false)
 
 	// Process patterns in order
 	for _, pattern := range gi.patterns {
+		// Rules of one repository never apply to another one
+		if pattern.repoRoot != repoRoot {
+			continue
+		}
 		if gi.matchesPattern(path, isDir, pattern) {
 			if pattern.isNegated {
 				result = false // Negated pattern includes the file
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Actions for files marked by .gitattributes
+const (
+	attrActionSkip    = "skip"
+	attrActionInclude = "include"
+	attrActionIgnore  = "ignore"
+)
+
+// Attributes that can be mapped to an action in the configuration, in order of evaluation
+var gitAttributeActions = []string{
+	"export-ignore",
+	"linguist-generated",
+	"linguist-vendored",
+	"linguist-documentation",
+	"binary",
+}
+
+// GitAttributes represents the .gitattributes files of a project
+type GitAttributes struct {
+	rules     []GitAttributeRule
+	repoRoots repoRoots
+}
+
+// GitAttributeRule represents a single .gitattributes line
+type GitAttributeRule struct {
+	pattern  string
+	attrs    map[string]string
+	basePath string
+	repoRoot string
+	depth    int
+}
+
+// Parse a .gitattributes line. Set attributes get "true", unset ones "false"
+// and unspecified ones an empty value.
+func parseGitAttributeRule(line, basePath string) *GitAttributeRule {
+	fields := strings.Fields(line)
+	// Skip empty lines, comments and macro definitions
+	if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[attr]") {
+		return nil
+	}
+	// Directories never get attributes
+	if strings.HasSuffix(fields[0], "/") {
+		return nil
+	}
+
+	rule := &GitAttributeRule{
+		pattern:  fields[0],
+		attrs:    make(map[string]string),
+		basePath: basePath,
+	}
+	for _, attr := range fields[1:] {
+		switch {
+		case strings.HasPrefix(attr, "-"):
+			rule.attrs[attr[1:]] = "false"
+		case strings.HasPrefix(attr, "!"):
+			rule.attrs[attr[1:]] = ""
+		default:
+			name, value, found := strings.Cut(attr, "=")
+			if !found {
+				value = "true"
+			}
+			rule.attrs[name] = value
+		}
+	}
+
+	// Built-in macro
+	if rule.attrs["binary"] == "true" {
+		rule.attrs["diff"] = "false"
+		rule.attrs["merge"] = "false"
+		rule.attrs["text"] = "false"
+	}
+	return rule
+}
+
+// Load .gitattributes files recursively
+func loadGitAttributes(rootPath string) (*GitAttributes, error) {
+	ga := &GitAttributes{}
+
+	err := filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if info.Name() == ".git" {
+			ga.repoRoots = append(ga.repoRoots, filepath.Dir(path))
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.Name() == ".gitattributes" && !info.IsDir() {
+			file, err := os.Open(path)
+			if err != nil {
+				return nil
+			}
+			defer func() {
+				_ = file.Close()
+			}()
+
+			basePath := filepath.Dir(path)
+			depth := strings.Count(basePath, string(filepath.Separator))
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				if rule := parseGitAttributeRule(scanner.Text(), basePath); rule != nil {
+					rule.depth = depth
+					ga.rules = append(ga.rules, *rule)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking directory: %w", err)
+	}
+
+	// Files in deeper directories override the ones above them
+	sort.SliceStable(ga.rules, func(i, j int) bool {
+		return ga.rules[i].depth < ga.rules[j].depth
+	})
+	for i := range ga.rules {
+		ga.rules[i].repoRoot = ga.repoRoots.rootFor(ga.rules[i].basePath, true)
+	}
+
+	return ga, nil
+}
+
+// Check if the rule pattern matches the file
+func (rule *GitAttributeRule) matches(path string) bool {
+	relPath, err := filepath.Rel(rule.basePath, path)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return false
+	}
+
+	pattern := strings.TrimPrefix(rule.pattern, "/")
+	// Patterns without a slash match the file name at any level
+	if !strings.Contains(rule.pattern, "/") {
+		return matchGlob(pattern, filepath.Base(path))
+	}
+	return matchGlob(filepath.FromSlash(pattern), relPath)
+}
+
+// Resolve the attributes of a file
+func (ga *GitAttributes) attributesFor(path string) map[string]string {
+	attrs := make(map[string]string)
+	if ga == nil {
+		return attrs
+	}
+
+	repoRoot := ga.repoRoots.rootFor(path, false)
+	for i := range ga.rules {
+		rule := &ga.rules[i]
+		if rule.repoRoot != repoRoot || !rule.matches(path) {
+			continue
+		}
+		for name, value := range rule.attrs {
+			if value == "" {
+				delete(attrs, name)
+			} else {
+				attrs[name] = value
+			}
+		}
+	}
+
+	if attrs["text"] == "false" {
+		attrs["binary"] = "true"
+	}
+	return attrs
+}
+
+// Map a linguist language name to a code block language identifier
+func linguistLanguage(name string) string {
+	known := map[string]string{
+		"batchfile":       "batch",
+		"c#":              "csharp",
+		"c++":             "cpp",
+		"emacs lisp":      "elisp",
+		"f#":              "fsharp",
+		"objective-c":     "objectivec",
+		"protocol buffer": "protobuf",
+		"shell":           "bash",
+		"vim script":      "vim",
+	}
+
+	name = strings.ToLower(strings.ReplaceAll(name, "_", " "))
+	if lang, exists := known[name]; exists {
+		return lang
+	}
+	return strings.ReplaceAll(name, " ", "")
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Run git inside dir and return its standard output
+func runGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(context.Background(), "git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// List files known to git below rootPath (relative to it).
+// Untracked files are added only if they are not ignored by git.
+func gitListFiles(rootPath string, untracked bool) ([]string, error) {
+	args := []string{"ls-files", "-z", "--cached"}
+	if untracked {
+		args = append(args, "--others", "--exclude-standard")
+	}
+	out, err := runGit(rootPath, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{})
+	var files []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name == "" {
+			continue
+		}
+		// Unmerged files are listed once per stage
+		if _, exists := seen[name]; exists {
+			continue
+		}
+		seen[name] = struct{}{}
+		files = append(files, filepath.FromSlash(name))
+	}
+	return files, nil
+}
+
+// GitInfo describes the repository state of the project
+type GitInfo struct {
+	Root   string
+	Branch string
+	Commit string
+	Dirty  bool
+	Remote string
+}
+
+// FileCommit describes the last commit that touched a file
+type FileCommit struct {
+	Hash   string
+	Author string
+	Date   time.Time
+}
+
+// Collect repository details for the archive header
+func loadGitInfo(path string) (*GitInfo, error) {
+	out, err := runGit(path, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	info := &GitInfo{
+		Root: filepath.FromSlash(strings.TrimSpace(string(out))),
+	}
+
+	// symbolic-ref also works for a branch without commits
+	if out, err := runGit(path, "symbolic-ref", "--short", "-q", "HEAD"); err == nil {
+		info.Branch = strings.TrimSpace(string(out))
+	} else {
+		info.Branch = "(detached HEAD)"
+	}
+
+	if out, err := runGit(path, "rev-parse", "HEAD"); err == nil {
+		info.Commit = strings.TrimSpace(string(out))
+	}
+
+	out, err = runGit(path, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return nil, err
+	}
+	info.Dirty = len(bytes.TrimSpace(out)) > 0
+
+	info.Remote = gitRemoteName(path, info.Branch)
+	return info, nil
+}
+
+// Pick the remote tracked by the branch, then origin, then the first configured one
+func gitRemoteName(path, branch string) string {
+	if out, err := runGit(path, "config", "--get", "branch."+branch+".remote"); err == nil {
+		if remote := strings.TrimSpace(string(out)); remote != "" && remote != "." {
+			return remote
+		}
+	}
+
+	out, err := runGit(path, "remote")
+	if err != nil {
+		return ""
+	}
+	remotes := strings.Fields(string(out))
+	for _, remote := range remotes {
+		if remote == "origin" {
+			return remote
+		}
+	}
+	if len(remotes) > 0 {
+		return remotes[0]
+	}
+	return ""
+}
+
+// Find the last commit for every file below path with a single git log call.
+// Keys are paths relative to path.
+func loadFileCommits(path string) (map[string]FileCommit, error) {
+	out, err := runGit(path, "log", "--relative", "--name-only", "-z", "--format=%x1e%H%x1f%an%x1f%aI", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	commits := make(map[string]FileCommit)
+	for _, record := range strings.Split(string(out), "\x1e") {
+		parts := strings.Split(record, "\x00")
+		fields := strings.Split(parts[0], "\x1f")
+		if len(fields) != 3 {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, fields[2])
+		if err != nil {
+			continue
+		}
+		commit := FileCommit{Hash: fields[0], Author: fields[1], Date: date}
+
+		// git log lists newer commits first
+		for _, name := range parts[1:] {
+			name = strings.TrimLeft(name, "\n")
+			if name == "" {
+				continue
+			}
+			name = filepath.FromSlash(name)
+			if _, exists := commits[name]; !exists {
+				commits[name] = commit
+			}
+		}
+	}
+	return commits, nil
+}
+
+// Count commits changing each file below path, file names are relative to path
+func loadFileChurn(path string) (map[string]int, error) {
+	out, err := runGit(path, "log", "--relative", "--name-only", "-z", "--format=", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	churn := make(map[string]int)
+	for _, name := range strings.Split(string(out), "\x00") {
+		name = strings.TrimLeft(name, "\n")
+		if name != "" {
+			churn[filepath.FromSlash(name)]++
+		}
+	}
+	return churn, nil
+}
+
+// Find the commit that last changed each line of a file, by line number.
+// Lines that are not committed yet have the author "Not Committed Yet".
+func loadBlame(dir, relPath string) (map[int]FileCommit, error) {
+	out, err := runGit(dir, "blame", "--line-porcelain", "--", filepath.ToSlash(relPath))
+	if err != nil {
+		return nil, err
+	}
+
+	blame := make(map[int]FileCommit)
+	var commit FileCommit
+	line := 0
+	// Every line of the file is a header, fields of its commit and the content prefixed with a tab
+	isHeader := true
+	for _, text := range strings.Split(string(out), "\n") {
+		switch {
+		case isHeader:
+			fields := strings.Fields(text)
+			if len(fields) < 3 {
+				continue
+			}
+			commit = FileCommit{Hash: fields[0]}
+			line, _ = strconv.Atoi(fields[2])
+			isHeader = false
+		case strings.HasPrefix(text, "\t"):
+			blame[line] = commit
+			isHeader = true
+		case strings.HasPrefix(text, "author "):
+			commit.Author = strings.TrimPrefix(text, "author ")
+		case strings.HasPrefix(text, "author-time "):
+			seconds, _ := strconv.ParseInt(strings.TrimPrefix(text, "author-time "), 10, 64)
+			commit.Date = time.Unix(seconds, 0)
+		}
+	}
+	return blame, nil
+}
+
+// Shorten a commit hash for display
+func shortHash(hash string) string {
+	const shortHashLen = 7
+	if len(hash) > shortHashLen {
+		return hash[:shortHashLen]
+	}
+	return hash
+}
+
+// Policies for git repositories nested in the project
+const (
+	submodulesSkip    = "skip"
+	submodulesInclude = "include"
+	submodulesRecurse = "recurse"
+)
+
+// NestedRepo describes a submodule or another git repository inside the project
+type NestedRepo struct {
+	Path      string
+	Submodule bool
+	Depth     int
+}
+
+// Check whether dir is the root of a git repository.
+// Submodules keep a .git file pointing to the gitdir of the parent repository.
+func detectGitRepo(dir string) (isRepo, isGitFile bool) {
+	gitPath := filepath.Join(dir, ".git")
+	info, err := os.Lstat(gitPath)
+	if err != nil {
+		return false, false
+	}
+	if info.IsDir() {
+		return true, false
+	}
+
+	content, err := os.ReadFile(gitPath)
+	if err != nil || !strings.HasPrefix(string(content), "gitdir:") {
+		return false, false
+	}
+	return true, true
+}
+
+// Read absolute submodule paths declared in .gitmodules of the repository at root
+func loadGitModules(root string) map[string]struct{} {
+	modules := make(map[string]struct{})
+	file, err := os.Open(filepath.Join(root, ".gitmodules"))
+	if err != nil {
+		return modules
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if found && strings.TrimSpace(key) == "path" {
+			modules[filepath.Join(root, filepath.FromSlash(strings.TrimSpace(value)))] = struct{}{}
+		}
+	}
+	return modules
+}
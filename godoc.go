@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/doc/comment"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// GoDocEntry is an exported declaration in a package summary
+type GoDocEntry struct {
+	Signature string
+	Synopsis  string
+	// Position of the declaration, the path is relative to the project
+	Path string
+	Line int
+	// Constructors, methods, constants and variables of a type
+	Members []GoDocEntry
+}
+
+// GoPackageDoc summarizes the exported API of a Go package
+type GoPackageDoc struct {
+	Name       string
+	ImportPath string
+	// Package documentation rendered as Markdown
+	Doc       string
+	Constants []GoDocEntry
+	Variables []GoDocEntry
+	Functions []GoDocEntry
+	Types     []GoDocEntry
+}
+
+// Headings in package documentation are nested below the heading of the package
+const goDocHeadingLevel = 4
+
+// Links to other packages in package documentation point to their documentation here
+const goDocBaseURL = "https://pkg.go.dev"
+
+// Summarize the packages of Go files in dir with go/doc. Sources are the archived content of the files by path,
+// files with different package names make separate packages. Paths of declarations are relative to root.
+func loadGoPackageDocs(dir string, sources map[string][]byte, root string) ([]*GoPackageDoc, error) {
+	fileSet := token.NewFileSet()
+	packages := make(map[string][]*ast.File)
+	for _, filePath := range sortedKeys(sources) {
+		file, err := parser.ParseFile(fileSet, filePath, sources[filePath], parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filePath, err)
+		}
+		packages[file.Name.Name] = append(packages[file.Name.Name], file)
+	}
+
+	importPath := goImportPath(dir, root)
+	var result []*GoPackageDoc
+	for _, name := range sortedKeys(packages) {
+		pkg, err := doc.NewFromFiles(fileSet, packages[name], importPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read documentation of %s: %w", importPath, err)
+		}
+
+		summary := &GoPackageDoc{Name: pkg.Name, ImportPath: importPath}
+		if pkg.Doc != "" {
+			docPrinter := pkg.Printer()
+			docPrinter.HeadingLevel = goDocHeadingLevel
+			// Headings get no IDs and links within the package are plain text, they would not resolve in the archive
+			docPrinter.HeadingID = func(*comment.Heading) string { return "" }
+			docPrinter.DocLinkURL = func(link *comment.DocLink) string {
+				if link.ImportPath == "" || link.ImportPath == importPath {
+					return ""
+				}
+				return link.DefaultURL(goDocBaseURL)
+			}
+			summary.Doc = strings.TrimSpace(string(docPrinter.Markdown(pkg.Parser().Parse(pkg.Doc))))
+		}
+
+		entry := func(signature, text string, pos token.Pos) GoDocEntry {
+			position := fileSet.Position(pos)
+			relPath, _ := filepath.Rel(root, position.Filename)
+			return GoDocEntry{
+				Signature: signature,
+				Synopsis:  pkg.Synopsis(text),
+				Path:      filepath.ToSlash(relPath),
+				Line:      position.Line,
+			}
+		}
+		values := func(values []*doc.Value) []GoDocEntry {
+			var entries []GoDocEntry
+			for _, value := range values {
+				signature := value.Decl.Tok.String() + " " + strings.Join(value.Names, ", ")
+				entries = append(entries, entry(signature, value.Doc, value.Decl.Specs[0].Pos()))
+			}
+			return entries
+		}
+		functions := func(functions []*doc.Func) []GoDocEntry {
+			var entries []GoDocEntry
+			for _, function := range functions {
+				entries = append(entries, entry(goFuncSignature(fileSet, function.Decl), function.Doc, function.Decl.Pos()))
+			}
+			return entries
+		}
+
+		summary.Constants = values(pkg.Consts)
+		summary.Variables = values(pkg.Vars)
+		summary.Functions = functions(pkg.Funcs)
+		for _, typ := range pkg.Types {
+			spec := goTypeSpec(typ)
+			if spec == nil {
+				continue
+			}
+			typeEntry := entry(goTypeSignature(fileSet, spec), typ.Doc, spec.Pos())
+			typeEntry.Members = append(typeEntry.Members, values(typ.Consts)...)
+			typeEntry.Members = append(typeEntry.Members, values(typ.Vars)...)
+			typeEntry.Members = append(typeEntry.Members, functions(typ.Funcs)...)
+			typeEntry.Members = append(typeEntry.Members, functions(typ.Methods)...)
+			summary.Types = append(summary.Types, typeEntry)
+		}
+		result = append(result, summary)
+	}
+	return result, nil
+}
+
+// Source text of the lines left in a file section. Removed lines become empty, so that declarations keep their
+// line numbers, and omission markers are dropped.
+func goSourceText(lines []SourceLine) []byte {
+	var buf bytes.Buffer
+	number := 1
+	for _, line := range lines {
+		if line.Number == 0 {
+			continue
+		}
+		for ; number < line.Number; number++ {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(line.Text + "\n")
+		number++
+	}
+	return buf.Bytes()
+}
+
+// Keep the declarations at the lines accepted by keep, members of kept types are filtered as well
+func (d *GoPackageDoc) filter(keep func(path string, line int) bool) {
+	d.Constants = filterGoDocEntries(d.Constants, keep)
+	d.Variables = filterGoDocEntries(d.Variables, keep)
+	d.Functions = filterGoDocEntries(d.Functions, keep)
+	d.Types = filterGoDocEntries(d.Types, keep)
+}
+
+// Paths of the files declaring the entries, in the order the entries are written
+func (d *GoPackageDoc) paths() []string {
+	var paths []string
+	var add func(entries []GoDocEntry)
+	add = func(entries []GoDocEntry) {
+		for _, entry := range entries {
+			paths = append(paths, entry.Path)
+			add(entry.Members)
+		}
+	}
+	for _, entries := range [][]GoDocEntry{d.Constants, d.Variables, d.Functions, d.Types} {
+		add(entries)
+	}
+	return paths
+}
+
+func filterGoDocEntries(entries []GoDocEntry, keep func(path string, line int) bool) []GoDocEntry {
+	var result []GoDocEntry
+	for _, entry := range entries {
+		if keep(entry.Path, entry.Line) {
+			entry.Members = filterGoDocEntries(entry.Members, keep)
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// Import path of the package in dir, relative to root if it is not in a module
+func goImportPath(dir, root string) string {
+	if module, err := findGoModule(dir, root); err == nil {
+		if relPath, err := filepath.Rel(module.Dir, dir); err == nil && relPath != "." {
+			return path.Join(module.Path, filepath.ToSlash(relPath))
+		}
+		return module.Path
+	}
+	relPath, _ := filepath.Rel(root, dir)
+	return filepath.ToSlash(relPath)
+}
+
+// Declaration of a type in its possibly grouped type declaration
+func goTypeSpec(typ *doc.Type) *ast.TypeSpec {
+	for _, spec := range typ.Decl.Specs {
+		if typeSpec, ok := spec.(*ast.TypeSpec); ok && typeSpec.Name.Name == typ.Name {
+			return typeSpec
+		}
+	}
+	return nil
+}
+
+// Signature of a function on a single line, without body and doc comment
+func goFuncSignature(fileSet *token.FileSet, decl *ast.FuncDecl) string {
+	signature := *decl
+	signature.Doc, signature.Body = nil, nil
+	return goNodeText(fileSet, &signature)
+}
+
+// Signature of a type: structs and interfaces without their fields, other types with their definition
+func goTypeSignature(fileSet *token.FileSet, spec *ast.TypeSpec) string {
+	signature := *spec
+	signature.Doc, signature.Comment = nil, nil
+	switch spec.Type.(type) {
+	case *ast.StructType:
+		signature.Type = ast.NewIdent("struct")
+	case *ast.InterfaceType:
+		signature.Type = ast.NewIdent("interface")
+	}
+	return "type " + goNodeText(fileSet, &signature)
+}
+
+// Print a syntax node on a single line, parameters spread over several lines are joined
+func goNodeText(fileSet *token.FileSet, node any) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fileSet, node); err != nil {
+		return ""
+	}
+	text := strings.Join(strings.Fields(buf.String()), " ")
+	return strings.NewReplacer("( ", "(", "[ ", "[", ", )", ")", ", ]", "]").Replace(text)
+}
+
+// Render the package summaries as Markdown with links to the file sections of the declarations.
+// Declarations of files without an anchor are not linked.
+func formatGoPackageDocs(packages []*GoPackageDoc, anchor func(relPath string) string) string {
+	var buf bytes.Buffer
+	writeEntry := func(entry GoDocEntry, indent string) {
+		if id := anchor(entry.Path); id != "" {
+			fmt.Fprintf(&buf, "%s- `%s` ([`%s:%d`](#%s))", indent, entry.Signature, entry.Path, entry.Line, id)
+		} else {
+			fmt.Fprintf(&buf, "%s- `%s` (`%s:%d`)", indent, entry.Signature, entry.Path, entry.Line)
+		}
+		if entry.Synopsis != "" {
+			fmt.Fprintf(&buf, ": %s", entry.Synopsis)
+		}
+		buf.WriteString("\n")
+	}
+	writeGroup := func(title string, entries []GoDocEntry) {
+		if len(entries) == 0 {
+			return
+		}
+		fmt.Fprintf(&buf, "**%s**\n\n", title)
+		for _, entry := range entries {
+			writeEntry(entry, "")
+			for _, member := range entry.Members {
+				writeEntry(member, "  ")
+			}
+		}
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString("## Go Packages\n\n")
+	for _, pkg := range packages {
+		fmt.Fprintf(&buf, "### %s (`%s`)\n\n", pkg.Name, pkg.ImportPath)
+		if pkg.Doc != "" {
+			buf.WriteString(pkg.Doc + "\n\n")
+		}
+		if len(pkg.Constants)+len(pkg.Variables)+len(pkg.Functions)+len(pkg.Types) == 0 {
+			buf.WriteString("*No exported declarations*\n\n")
+			continue
+		}
+		writeGroup("Constants", pkg.Constants)
+		writeGroup("Variables", pkg.Variables)
+		writeGroup("Functions", pkg.Functions)
+		writeGroup("Types", pkg.Types)
+	}
+	buf.WriteString("---\n\n")
+	return buf.String()
+}
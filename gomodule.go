@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GoModule is a Go module on disk
+type GoModule struct {
+	Path string
+	Dir  string
+	// Import paths of modules replaced by local directories
+	Replaces map[string]string
+}
+
+// Parse the module path and local replace directives of a go.mod file
+func loadGoModule(dir string) (*GoModule, error) {
+	content, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read go.mod: %w", err)
+	}
+
+	module := &GoModule{Dir: dir, Replaces: make(map[string]string)}
+	inReplace := false
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "//")
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case fields[0] == "module" && len(fields) > 1:
+			module.Path = unquoteModulePath(fields[1])
+		case fields[0] == "replace" && len(fields) > 1 && fields[1] == "(":
+			inReplace = true
+		case inReplace && fields[0] == ")":
+			inReplace = false
+		case fields[0] == "replace":
+			module.addReplace(fields[1:])
+		case inReplace:
+			module.addReplace(fields)
+		}
+	}
+
+	if module.Path == "" {
+		return nil, fmt.Errorf("no module path in %s", filepath.Join(dir, "go.mod"))
+	}
+	return module, nil
+}
+
+// Record a replace directive like "example.com/lib v1.0.0 => ../lib" if it points to a directory
+func (m *GoModule) addReplace(fields []string) {
+	arrow := -1
+	for i, field := range fields {
+		if field == "=>" {
+			arrow = i
+		}
+	}
+	if arrow <= 0 || arrow+1 >= len(fields) {
+		return
+	}
+
+	target := unquoteModulePath(fields[arrow+1])
+	if !strings.HasPrefix(target, "./") && !strings.HasPrefix(target, "../") && !filepath.IsAbs(target) {
+		return
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(m.Dir, filepath.FromSlash(target))
+	}
+	m.Replaces[unquoteModulePath(fields[0])] = target
+}
+
+func unquoteModulePath(path string) string {
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		return unquoted
+	}
+	return path
+}
+
+// Find the go.mod of the module containing dir, not looking above root
+func findGoModule(dir, root string) (*GoModule, error) {
+	for current := dir; current == root || isSubPath(root, current); current = filepath.Dir(current) {
+		if exists, _ := pathExists(filepath.Join(current, "go.mod")); exists {
+			return loadGoModule(current)
+		}
+		if current == root {
+			break
+		}
+	}
+	return nil, fmt.Errorf("no go.mod found for %s", dir)
+}
+
+// GoPackageGraph holds in-module packages reachable from entry packages
+type GoPackageGraph struct {
+	modules []*GoModule
+	// Imports of each package directory, limited to in-module packages
+	imports map[string][]string
+	// Go files of each package directory
+	files map[string][]string
+}
+
+// Compute the transitive closure of in-module imports of the entry package directories.
+// Packages outside a module or failing to parse are left out, the graph holds the other packages
+// and the returned error joins the errors of those left out.
+func loadGoPackageGraph(entries []string, root string, withTests bool) (*GoPackageGraph, error) {
+	graph := &GoPackageGraph{
+		imports: make(map[string][]string),
+		files:   make(map[string][]string),
+	}
+
+	var errs []error
+	queue := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		module, err := findGoModule(entry, root)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		graph.addModule(module)
+		queue = append(queue, entry)
+	}
+
+	failed := make(map[string]bool)
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		if _, visited := graph.files[dir]; visited || failed[dir] {
+			continue
+		}
+
+		files, imports, err := parseGoPackage(dir, withTests)
+		if err != nil {
+			errs = append(errs, err)
+			failed[dir] = true
+			continue
+		}
+		graph.files[dir] = files
+		for _, importPath := range imports {
+			dep := graph.resolve(importPath)
+			if dep == "" || dep == dir {
+				continue
+			}
+			graph.imports[dir] = append(graph.imports[dir], dep)
+			queue = append(queue, dep)
+		}
+	}
+	return graph, errors.Join(errs...)
+}
+
+// Add the module and modules it replaces with local directories
+func (g *GoPackageGraph) addModule(module *GoModule) {
+	for _, known := range g.modules {
+		if known.Dir == module.Dir {
+			return
+		}
+	}
+	g.modules = append(g.modules, module)
+	for path, dir := range module.Replaces {
+		if replaced, err := loadGoModule(dir); err == nil {
+			replaced.Path = path
+			g.addModule(replaced)
+		}
+	}
+}
+
+// Directory of an in-module package, empty for standard library and external packages
+func (g *GoPackageGraph) resolve(importPath string) string {
+	var best *GoModule
+	for _, module := range g.modules {
+		if importPath != module.Path && !strings.HasPrefix(importPath, module.Path+"/") {
+			continue
+		}
+		if best == nil || len(module.Path) > len(best.Path) {
+			best = module
+		}
+	}
+	if best == nil {
+		return ""
+	}
+
+	dir := filepath.Join(best.Dir, filepath.FromSlash(strings.TrimPrefix(importPath, best.Path)))
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return ""
+	}
+	return dir
+}
+
+// List Go files of the package in dir and the paths they import
+func parseGoPackage(dir string, withTests bool) (files, imports []string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read package %s: %w", dir, err)
+	}
+
+	seen := make(map[string]bool)
+	fileSet := token.NewFileSet()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || (!withTests && strings.HasSuffix(name, "_test.go")) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		files = append(files, path)
+
+		file, err := parser.ParseFile(fileSet, path, nil, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+		for _, spec := range file.Imports {
+			importPath, err := strconv.Unquote(spec.Path.Value)
+			if err == nil && !seen[importPath] {
+				seen[importPath] = true
+				imports = append(imports, importPath)
+			}
+		}
+	}
+	sort.Strings(imports)
+	return files, imports, nil
+}
+
+// Package directories with dependencies before the packages importing them
+func (g *GoPackageGraph) packageOrder() []string {
+	return dependencyOrder(g.imports, sortedKeys(g.files))
+}
+
+// Order nodes so that dependencies come first. Ties and cycles are resolved by the order of nodes.
+func dependencyOrder(edges map[string][]string, nodes []string) []string {
+	var order []string
+	state := make(map[string]int)
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	var visit func(node string)
+	visit = func(node string) {
+		if state[node] != 0 {
+			return
+		}
+		state[node] = visiting
+		deps := append([]string{}, edges[node]...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			visit(dep)
+		}
+		state[node] = visited
+		order = append(order, node)
+	}
+
+	for _, node := range nodes {
+		visit(node)
+	}
+	return order
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
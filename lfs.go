@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Policies for Git LFS pointer files
+const (
+	lfsSkip     = "skip"
+	lfsAnnotate = "annotate"
+	lfsResolve  = "resolve"
+)
+
+// Pointer files are always smaller than this
+const lfsPointerMaxSize = 1024
+
+// LFSPointer describes a Git LFS pointer file
+type LFSPointer struct {
+	OID  string
+	Size int64
+}
+
+// Parse content as a Git LFS pointer file, nil if it is something else
+func parseLFSPointer(content []byte) *LFSPointer {
+	if len(content) >= lfsPointerMaxSize || !bytes.HasPrefix(content, []byte("version https://git-lfs.github.com/spec/")) {
+		return nil
+	}
+
+	pointer := &LFSPointer{Size: -1}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), " ")
+		if !found {
+			return nil
+		}
+		switch key {
+		case "oid":
+			pointer.OID = strings.TrimPrefix(value, "sha256:")
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil
+			}
+			pointer.Size = size
+		}
+	}
+
+	const oidLength = 64
+	if len(pointer.OID) != oidLength || pointer.Size < 0 {
+		return nil
+	}
+	return pointer
+}
+
+// Location of the object in the LFS storage of a repository
+func (pointer *LFSPointer) objectPath(gitDir string) string {
+	return filepath.Join(gitDir, "lfs", "objects", pointer.OID[0:2], pointer.OID[2:4], pointer.OID)
+}
+
+// Find the git directory holding LFS objects of the repository at dir
+func gitCommonDir(dir string) (string, error) {
+	out, err := runGit(dir, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+	gitDir := filepath.FromSlash(strings.TrimSpace(string(out)))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(dir, gitDir)
+	}
+	return gitDir, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Strategies for files exceeding size limits
+const (
+	truncateSkip       = "skip"
+	truncateHead       = "head"
+	truncateHeadTail   = "head_tail"
+	truncateHeadMarker = "head_marker"
+)
+
+// LimitsConfig restricts how much content gets into the archive.
+// Rules hold per extension (".json") or per glob ("*.min.js") file limits.
+type LimitsConfig struct {
+	MaxFileBytes  int64                   `json:"max_file_bytes,omitempty"`
+	MaxFileLines  int                     `json:"max_file_lines,omitempty"`
+	MaxTotalBytes int64                   `json:"max_total_bytes,omitempty"`
+	MaxFiles      int                     `json:"max_files,omitempty"`
+	Truncate      string                  `json:"truncate,omitempty"`
+	TailLines     int                     `json:"tail_lines,omitempty"`
+	Rules         map[string]LimitsConfig `json:"rules,omitempty"`
+}
+
+// Return a copy of the limits with non-zero values of other applied
+func (l LimitsConfig) override(other LimitsConfig) LimitsConfig {
+	if other.MaxFileBytes > 0 {
+		l.MaxFileBytes = other.MaxFileBytes
+	}
+	if other.MaxFileLines > 0 {
+		l.MaxFileLines = other.MaxFileLines
+	}
+	if other.MaxTotalBytes > 0 {
+		l.MaxTotalBytes = other.MaxTotalBytes
+	}
+	if other.MaxFiles > 0 {
+		l.MaxFiles = other.MaxFiles
+	}
+	if other.Truncate != "" {
+		l.Truncate = other.Truncate
+	}
+	if other.TailLines > 0 {
+		l.TailLines = other.TailLines
+	}
+	return l
+}
+
+// Apply the rules matching the file, extension rules first and longer globs last
+func (l LimitsConfig) forFile(relPath string, rules map[string]LimitsConfig) LimitsConfig {
+	var patterns []string
+	for pattern := range rules {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if isExtensionRule(patterns[i]) != isExtensionRule(patterns[j]) {
+			return isExtensionRule(patterns[i])
+		}
+		return len(patterns[i]) < len(patterns[j])
+	})
+
+	for _, pattern := range patterns {
+		if isExtensionRule(pattern) {
+			if !strings.EqualFold(fileExtension(relPath), pattern) {
+				continue
+			}
+		} else if !matchPathGlob(pattern, relPath) {
+			continue
+		}
+		l = l.override(rules[pattern])
+	}
+	return l
+}
+
+// Rules like ".json" apply to an extension, anything else is a glob
+func isExtensionRule(pattern string) bool {
+	return strings.HasPrefix(pattern, ".") && !strings.ContainsAny(pattern, "*?/\\")
+}
+
+// Truncation records a file cut to fit the limits
+type Truncation struct {
+	Path          string
+	Reason        string
+	OriginalLines int
+	KeptLines     int
+}
+
+// Check lines against the file limits. Returns the lines to keep and the violated limit,
+// or an empty reason if the file fits.
+func truncateLines(lines []SourceLine, limits LimitsConfig) (result []SourceLine, reason string) {
+	head, tail := len(lines), 0
+	if limits.MaxFileLines > 0 && len(lines) > limits.MaxFileLines {
+		reason = fmt.Sprintf("more than %d lines", limits.MaxFileLines)
+		head = limits.MaxFileLines
+		if limits.Truncate == truncateHeadTail {
+			tail = min(limits.TailLines, head/2)
+			head -= tail
+		}
+	}
+
+	if limits.MaxFileBytes > 0 && linesSize(lines) > limits.MaxFileBytes {
+		reason = fmt.Sprintf("larger than %s", formatFileSize(limits.MaxFileBytes))
+		budget := limits.MaxFileBytes
+		if limits.Truncate == truncateHeadTail {
+			if tail == 0 {
+				tail = min(limits.TailLines, len(lines)/2)
+				head = len(lines) - tail
+			}
+			tail = fitLines(lines[len(lines)-tail:], budget/2, true)
+			budget -= linesSize(lines[len(lines)-tail:])
+		}
+		head = fitLines(lines[:head], budget, false)
+	}
+
+	if reason == "" {
+		return lines, ""
+	}
+	if limits.Truncate == truncateSkip {
+		return nil, reason
+	}
+
+	result = append(result, lines[:head]...)
+	if limits.Truncate != truncateHead {
+		result = append(result, omissionMarker(len(lines)-head-tail, "truncated"))
+	}
+	result = append(result, lines[len(lines)-tail:]...)
+	return result, reason
+}
+
+// Count how many lines from the start (or from the end) fit into budget bytes
+func fitLines(lines []SourceLine, budget int64, fromEnd bool) int {
+	count := 0
+	var size int64
+	for i := range lines {
+		line := lines[i]
+		if fromEnd {
+			line = lines[len(lines)-1-i]
+		}
+		size += int64(len(line.Text)) + 1
+		if size > budget {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// Size of lines written with line breaks
+func linesSize(lines []SourceLine) int64 {
+	var size int64
+	for _, line := range lines {
+		size += int64(len(line.Text)) + 1
+	}
+	return size
+}
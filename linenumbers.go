@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LineNumbersConfig controls line numbers in code blocks.
+// Width is the minimum width of the numbers, they are as wide as the last line number of a file by default.
+type LineNumbersConfig struct {
+	Enabled   bool   `json:"enabled,omitempty"`
+	Width     int    `json:"width,omitempty"`
+	Separator string `json:"separator,omitempty"`
+}
+
+// Return a copy of the settings with non-zero values of other applied
+func (l LineNumbersConfig) override(other LineNumbersConfig) LineNumbersConfig {
+	if other.Enabled {
+		l.Enabled = true
+	}
+	if other.Width > 0 {
+		l.Width = other.Width
+	}
+	if other.Separator != "" {
+		l.Separator = other.Separator
+	}
+	return l
+}
+
+// Header line recording the separator, so that resolve can read the numbers back
+const lineNumbersHeader = "Line numbers: separator "
+
+// Prefix lines with their numbers in the original file. Lines added by project2md get a blank prefix.
+func numberLines(lines []SourceLine, settings LineNumbersConfig) []string {
+	width := settings.Width
+	for _, line := range lines {
+		width = max(width, len(strconv.Itoa(line.Number)))
+	}
+
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		prefix := strings.Repeat(" ", width)
+		if line.Number > 0 {
+			prefix = fmt.Sprintf("%*d", width, line.Number)
+		}
+		result[i] = prefix + settings.Separator + line.Text
+		// No trailing spaces on blank lines
+		if strings.TrimSuffix(line.Text, "\r") == "" {
+			result[i] = strings.TrimRight(prefix+settings.Separator, " ") + line.Text
+		}
+	}
+	return result
+}
+
+// Patterns of archive content read back by resolve
+var (
+	sectionHeaderRegexp = regexp.MustCompile(`^=== (.+?)(?: \(lines ([\d, -]+)\))? ===$`)
+	omissionRegexp      = regexp.MustCompile(`^\.\.\. (\d+) lines? omitted \(.+\) \.\.\.$`)
+)
+
+// archiveSection is the code block of a file in an archive, with original line numbers
+type archiveSection struct {
+	path  string
+	lines []SourceLine
+	// Lines were removed without omission markers by outlining or stripping and the archive has no line numbers,
+	// so the original numbers cannot be counted
+	uncounted bool
+}
+
+// Read the code blocks of an archive. With line numbers the lines keep the numbers written in the archive,
+// otherwise they are counted from the start of the section, skipping the lines stated by omission markers.
+func readArchive(path string) (map[string]*archiveSection, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	sections := make(map[string]*archiveSection)
+	var section *archiveSection
+	separator := ""
+	stripped := false
+	inBlock := false
+	next := 1
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxArchiveLineLength)
+	for scanner.Scan() {
+		text := scanner.Text()
+		switch {
+		case inBlock && text == "```":
+			inBlock = false
+		case inBlock:
+			section.lines = append(section.lines, archiveLine(text, separator, &next))
+		case strings.HasPrefix(text, lineNumbersHeader) && section == nil:
+			if separator, err = strconv.Unquote(strings.TrimPrefix(text, lineNumbersHeader)); err != nil {
+				return nil, fmt.Errorf("invalid line numbers header %q", text)
+			}
+		case text == strippedHeader && section == nil:
+			stripped = true
+		case sectionHeaderRegexp.MatchString(text):
+			match := sectionHeaderRegexp.FindStringSubmatch(text)
+			section = &archiveSection{path: filepath.ToSlash(match[1]), uncounted: stripped && separator == ""}
+			sections[section.path] = section
+			next = 1
+			if match[2] != "" {
+				next, _ = strconv.Atoi(strings.Split(match[2], "-")[0])
+			}
+		case section != nil && text == "*"+outlineNote+"*" && section.lines == nil:
+			section.uncounted = separator == ""
+		case section != nil && strings.HasPrefix(text, "```") && section.lines == nil:
+			inBlock = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	return sections, nil
+}
+
+// Longest line of an archive that can be read
+const maxArchiveLineLength = 16 * 1024 * 1024
+
+// Original number and text of a line of a code block. Without separator the number is next,
+// which is advanced past the line or the lines an omission marker stands for.
+func archiveLine(text, separator string, next *int) SourceLine {
+	if separator != "" {
+		return numberedArchiveLine(text, separator)
+	}
+
+	if match := omissionRegexp.FindStringSubmatch(text); match != nil {
+		count, _ := strconv.Atoi(match[1])
+		*next += count
+		return SourceLine{Text: text}
+	}
+	line := SourceLine{Number: *next, Text: text}
+	*next++
+	return line
+}
+
+// Parse a line written by numberLines: the number right-aligned in spaces, or only spaces for lines
+// added by project2md, then the separator and the text
+func numberedArchiveLine(text, separator string) SourceLine {
+	spaces := len(text) - len(strings.TrimLeft(text, " "))
+	digits := spaces
+	for digits < len(text) && text[digits] >= '0' && text[digits] <= '9' {
+		digits++
+	}
+
+	rest := text[digits:]
+	if digits == spaces {
+		// Leading spaces of the separator are part of the blank prefix
+		separatorSpaces := len(separator) - len(strings.TrimLeft(separator, " "))
+		rest = text[max(0, spaces-separatorSpaces):]
+	}
+	switch {
+	case strings.HasPrefix(rest, separator):
+		rest = rest[len(separator):]
+	case strings.TrimSuffix(rest, "\r") == strings.TrimRight(separator, " "):
+		// Blank lines are written without the trailing spaces of the separator
+		rest = rest[len(strings.TrimRight(separator, " ")):]
+	default:
+		return SourceLine{Text: text}
+	}
+
+	number, _ := strconv.Atoi(text[spaces:digits])
+	return SourceLine{Number: number, Text: rest}
+}
+
+// Split a citation like "src/main.go:12" or "src/main.go:12-20" into the path and the line range
+func parseCitation(citation string) (string, lineRange, error) {
+	colon := strings.LastIndex(citation, ":")
+	if colon <= 0 {
+		return "", lineRange{}, fmt.Errorf("invalid citation %q, expected path:line or path:start-end", citation)
+	}
+
+	path, lines := citation[:colon], citation[colon+1:]
+	from, to, isRange := strings.Cut(lines, "-")
+	start, err := strconv.Atoi(from)
+	end := start
+	if err == nil && isRange {
+		end, err = strconv.Atoi(to)
+	}
+	if err != nil || start < 1 || end < start {
+		return "", lineRange{}, fmt.Errorf("invalid line numbers in citation %q", citation)
+	}
+	return filepath.ToSlash(filepath.Clean(path)), lineRange{start, end}, nil
+}
+
+// Print the lines of files cited as path:line from an archive
+func resolveCitations(archivePath string, citations []string) error {
+	sections, err := readArchive(archivePath)
+	if err != nil {
+		return err
+	}
+
+	for _, citation := range citations {
+		path, cited, err := parseCitation(citation)
+		if err != nil {
+			return err
+		}
+		section, exists := sections[path]
+		if !exists {
+			return fmt.Errorf("file %s is not in the archive", path)
+		}
+		if section.uncounted {
+			return fmt.Errorf("cannot resolve %s: %s is outlined or stripped, which needs an archive with line numbers", citation, path)
+		}
+
+		lines := make(map[int]string)
+		for _, line := range section.lines {
+			if line.Number > 0 {
+				lines[line.Number] = line.Text
+			}
+		}
+		// Runs of lines missing in the archive are reported once
+		missing := 0
+		reportMissing := func(number int) {
+			if missing == 1 {
+				fmt.Printf("%s:%d: not in the archive\n", path, number-1)
+			} else if missing > 1 {
+				fmt.Printf("%s:%d-%d: not in the archive\n", path, number-missing, number-1)
+			}
+			missing = 0
+		}
+		for number := cited.start; number <= cited.end; number++ {
+			text, exists := lines[number]
+			if !exists {
+				missing++
+				continue
+			}
+			reportMissing(number)
+			fmt.Printf("%s:%d: %s\n", path, number, text)
+		}
+		reportMissing(cited.end + 1)
+	}
+	return nil
+}
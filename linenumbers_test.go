@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestNumberLinesRoundTrip(t *testing.T) {
+	lines := []SourceLine{
+		{Number: 8, Text: "func main() {"},
+		{Number: 9, Text: ""},
+		{Number: 10, Text: "\tfmt.Println(\"a | b\")"},
+		{Text: "... 87 lines omitted (truncated) ..."},
+		{Number: 98, Text: "  indented: 1"},
+		{Number: 99, Text: "\r"},
+		{Number: 100, Text: "}\r"},
+		{Text: ""},
+	}
+
+	for _, separator := range []string{" | ", ": ", "  ", " ", "|", "\t", " -> "} {
+		for _, width := range []int{0, 5} {
+			settings := LineNumbersConfig{Enabled: true, Width: width, Separator: separator}
+			for i, text := range numberLines(lines, settings) {
+				got := numberedArchiveLine(text, separator)
+				if got != lines[i] {
+					t.Errorf("separator %q, width %d: %q read back as %+v, want %+v", separator, width, text, got, lines[i])
+				}
+			}
+		}
+	}
+}
+
+func TestNumberedArchiveLineWithoutSeparator(t *testing.T) {
+	// Lines that do not carry the separator are kept as added lines
+	got := numberedArchiveLine("12 no separator", " | ")
+	if want := (SourceLine{Text: "12 no separator"}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -2,13 +2,18 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// Exit code of a run stopped by -fail-on-secrets
+const exitSecretsFound = 3
+
 // Export default configuration to a file
 func exportDefaultConfig(configPath string) error {
 	config := getDefaultConfig()
@@ -31,6 +36,18 @@ func exportDefaultConfig(configPath string) error {
 }
 
 func main() {
+	// Subcommands come before any options
+	if len(os.Args) > 1 && os.Args[1] == "resolve" {
+		if len(os.Args) < 4 {
+			fmt.Printf("Usage: %s resolve <archive> <path:line>...\n", filepath.Base(os.Args[0]))
+			os.Exit(1)
+		}
+		if err := resolveCitations(os.Args[2], os.Args[3:]); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
 	var (
 		userConfigPath = flag.String("config", "", "Path to user configuration file")
 		exportPath     = flag.String("export", "", "Export default configuration file to specified path")
@@ -39,7 +56,49 @@ func main() {
 		showStats      = flag.Bool("stat", false, "Include file size information and statistics in output")
 		version        = flag.Bool("version", false, "Show version information")
 		noGit          = flag.Bool("no-git", false, "Do not use .gitignore for exclude files")
+		gitFiles       = flag.Bool("git-files", false, "Take the file list from git ls-files instead of walking the directory")
+		gitUntracked   = flag.Bool("git-untracked", false, "With -git-files, also include untracked files that are not ignored")
+		gitInfo        = flag.Bool("git-info", false, "Include git repository details and last commit of each file")
+		submodules     = flag.String("submodules", "", "Nested git repositories policy: skip, include or recurse")
+		lfs            = flag.String("lfs", "", "Git LFS pointer files policy: skip, annotate or resolve")
+		maxFileBytes   = flag.Int64("max-file-bytes", 0, "Maximum size of a single file in bytes")
+		maxFileLines   = flag.Int("max-file-lines", 0, "Maximum number of lines of a single file")
+		maxTotalBytes  = flag.Int64("max-total-bytes", 0, "Maximum total size of file contents in the archive")
+		maxFiles       = flag.Int("max-files", 0, "Maximum number of files in the archive")
+		truncate       = flag.String("truncate", "", "Strategy for files over the limits: skip, head, head_tail or head_marker")
+		generated      = flag.String("generated", "", "Generated files policy: skip, stub or include")
+		minified       = flag.String("minified", "", "Minified files policy: skip, stub or include")
+		noRedact       = flag.Bool("no-redact", false, "Do not replace detected secrets with placeholders")
+		failOnSecrets  = flag.Bool("fail-on-secrets", false, "Write nothing and exit with code 3 if secrets are found")
+		allowlistPath  = flag.String("secrets-allowlist", "", "File with fingerprints of accepted secret findings")
+		outline        = flag.Bool("outline", false, "Render files as declarations and signatures without bodies")
+		strip          = flag.Bool("strip", false, "Remove comments, trailing whitespace and runs of blank lines")
+		stripKeepDocs  = flag.Bool("strip-keep-docs", false, "With -strip, keep doc comments and license headers")
+		goNoTests      = flag.Bool("go-no-tests", false, "With -go-entry, leave out _test.go files")
+		order          = flag.String("order", "", "Order of files: name, deps, size, mtime or churn")
+		query          = flag.String("query", "", "Archive only files relevant to the query text")
+		queryTop       = flag.Int("query-top", 20, "With -query, maximum number of files, 0 for no limit")
+		queryBudget    = flag.Int64("query-budget", 0, "With -query, maximum total size of selected files in bytes")
+		queryTokens    = flag.Int64("query-tokens", 0, "With -query, maximum total size of selected files in estimated tokens")
+		orderPriority  = flag.String("order-priority", "", "Comma-separated globs of files written first, * marks the other files")
+		deps           = flag.Bool("deps", false, "Add a Dependencies section summarizing manifests and lockfiles")
+		statsJSON      = flag.String("stats-json", "", "Write statistics by language and directory as JSON to this file")
+		lineNumbers    = flag.Bool("line-numbers", false, "Prefix lines of code blocks with their numbers in the original file")
+		numberWidth    = flag.Int("line-number-width", 0, "With -line-numbers, minimum width of the numbers")
+		numberSep      = flag.String("line-number-separator", "", "With -line-numbers, text between number and line (default: \" | \")")
+		annotations    = flag.Bool("annotations", false, "Add an Annotations section listing TODO, FIXME, HACK and XXX comments")
+		annotationTags = flag.String("annotation-tags", "", "With -annotations, comma-separated extra tags like NOTE,OPTIMIZE")
+		annotationBy   = flag.Bool("annotation-blame", false, "With -annotations, add the author of each annotation from git blame")
+		goDoc          = flag.Bool("go-doc", false, "Add a Go Packages section summarizing the exported API of each package")
+		mermaidTree    = flag.Int("mermaid-tree", 0, "Add a Mermaid diagram of directories down to this depth")
+		mermaidGo      = flag.Bool("mermaid-go", false, "Add a Mermaid diagram of imports between Go packages, highlighting cycles")
+		goEntries      stringList
+		entries        stringList
+		symbols        stringList
 	)
+	flag.Var(&entries, "entry", "JavaScript, TypeScript or Python file to start from, only files it imports are archived (repeatable)")
+	flag.Var(&goEntries, "go-entry", "Go package directory to start from, only packages it imports are archived (repeatable)")
+	flag.Var(&symbols, "symbol", "Archive only this declaration, like pkg.Func or Type.Method (repeatable)")
 	flag.Parse()
 
 	// Show version
@@ -62,21 +121,75 @@ func main() {
 	args := flag.Args()
 	if len(args) == 0 {
 		exeFile := filepath.Base(os.Args[0])
-		fmt.Printf("Usage: %s [options] <project_directory>\n\n", exeFile)
+		fmt.Printf("Usage: %s [options] <project_directory>\n", exeFile)
+		fmt.Printf("       %s resolve <archive> <path:line>...\n\n", exeFile)
 		fmt.Println("Options:")
 		fmt.Println("  -config <path>      Path to user configuration file")
 		fmt.Println("  -export <path>      Export default configuration to file")
 		fmt.Println("  -output <filename>  Output file name (default: project.md)")
 		fmt.Println("  -verbose            Enable verbose output with file details")
 		fmt.Println("  -stat               Include file size information and statistics in output")
+		fmt.Println("  -deps               Add a Dependencies section summarizing manifests and lockfiles")
+		fmt.Println("  -mermaid-tree <depth> Add a Mermaid diagram of directories down to this depth")
+		fmt.Println("  -mermaid-go         Add a Mermaid diagram of imports between Go packages, highlighting cycles")
+		fmt.Println("  -go-doc             Add a Go Packages section summarizing the exported API of each package")
+		fmt.Println("  -annotations        Add an Annotations section listing TODO, FIXME, HACK and XXX comments")
+		fmt.Println("  -annotation-tags <tags> With -annotations, comma-separated extra tags like NOTE,OPTIMIZE")
+		fmt.Println("  -annotation-blame   With -annotations, add the author of each annotation from git blame")
+		fmt.Println("  -stats-json <file>  Write statistics by language and directory as JSON to this file")
 		fmt.Println("  -version            Show version information")
 		fmt.Println("  -no-git             Do not use .gitignore for exclude files")
+		fmt.Println("  -git-files          Take the file list from git ls-files instead of walking the directory")
+		fmt.Println("  -git-untracked      With -git-files, also include untracked files that are not ignored")
+		fmt.Println("  -git-info           Include git repository details and last commit of each file")
+		fmt.Println("  -submodules <mode>  Nested git repositories policy: skip, include or recurse (default: recurse)")
+		fmt.Println("  -lfs <mode>         Git LFS pointer files policy: skip, annotate or resolve (default: annotate)")
+		fmt.Println("  -max-file-bytes <n> Maximum size of a single file in bytes")
+		fmt.Println("  -max-file-lines <n> Maximum number of lines of a single file")
+		fmt.Println("  -max-total-bytes <n> Maximum total size of file contents in the archive")
+		fmt.Println("  -max-files <n>      Maximum number of files in the archive")
+		fmt.Println("  -truncate <mode>    Strategy for files over the limits: skip, head, head_tail or head_marker")
+		fmt.Println("  -generated <mode>   Generated files policy: skip, stub or include (default: stub)")
+		fmt.Println("  -minified <mode>    Minified files policy: skip, stub or include (default: stub)")
+		fmt.Println("  -no-redact          Do not replace detected secrets with placeholders")
+		fmt.Println("  -fail-on-secrets    Write nothing and exit with code 3 if secrets are found")
+		fmt.Println("  -secrets-allowlist <file> File with fingerprints of accepted secret findings")
+		fmt.Println("  -entry <file>       JS/TS or Python file to start from, only files it imports are archived (repeatable)")
+		fmt.Println("  -go-entry <dir>     Go package to start from, only packages it imports are archived (repeatable)")
+		fmt.Println("  -go-no-tests        With -go-entry, leave out _test.go files")
+		fmt.Println("  -query <text>       Archive only files relevant to the query text")
+		fmt.Println("  -query-top <n>      With -query, maximum number of files, 0 for no limit (default: 20)")
+		fmt.Println("  -query-budget <n>   With -query, maximum total size of selected files in bytes")
+		fmt.Println("  -query-tokens <n>   With -query, maximum total size of selected files in estimated tokens")
+		fmt.Println("  -symbol <name>      Archive only this declaration, like pkg.Func or Type.Method (repeatable)")
+		fmt.Println("  -order <mode>       Order of files: name, deps, size, mtime or churn (default: name)")
+		fmt.Println("  -order-priority <globs> Comma-separated globs of files written first, * marks the other files")
+		fmt.Println("  -outline            Render files as declarations and signatures without bodies")
+		fmt.Println("  -strip              Remove comments, trailing whitespace and runs of blank lines")
+		fmt.Println("  -strip-keep-docs    With -strip, keep doc comments and license headers")
+		fmt.Println("  -line-numbers       Prefix lines of code blocks with their numbers in the original file")
+		fmt.Println("  -line-number-width <n> With -line-numbers, minimum width of the numbers")
+		fmt.Println("  -line-number-separator <text> With -line-numbers, text between number and line (default: \" | \")")
 		fmt.Println()
 		fmt.Println("Examples:")
 		fmt.Printf("  %s ./my-project\n", exeFile)
 		fmt.Printf("  %s -config config.json ./my-project\n", exeFile)
 		fmt.Printf("  %s -export default-config.json\n", exeFile)
 		fmt.Printf("  %s -verbose -stat -config -no-git config.json -output ./my-project/project.md ./my-project\n", exeFile)
+		fmt.Printf("  %s -git-files -git-untracked -git-info ./my-project\n", exeFile)
+		fmt.Printf("  %s -max-file-lines 500 -truncate head_tail -max-total-bytes 1000000 ./my-project\n", exeFile)
+		fmt.Printf("  %s -fail-on-secrets -secrets-allowlist .secrets-allowlist ./my-project\n", exeFile)
+		fmt.Printf("  %s -strip -strip-keep-docs ./my-project\n", exeFile)
+		fmt.Printf("  %s -outline ./my-project\n", exeFile)
+		fmt.Printf("  %s -query \"rate limiter\" -query-tokens 30000 -verbose ./my-project\n", exeFile)
+		fmt.Printf("  %s -symbol Processor.Process -symbol main.loadCustomConfig ./my-project\n", exeFile)
+		fmt.Printf("  %s -order deps -order-priority 'README*,*,*_test.go' ./my-project\n", exeFile)
+		fmt.Printf("  %s -go-entry ./cmd/server -go-no-tests ./my-project\n", exeFile)
+		fmt.Printf("  %s -entry src/main.tsx -entry tools/build.py ./my-project\n", exeFile)
+		fmt.Printf("  %s -line-numbers -line-number-separator ': ' ./my-project\n", exeFile)
+		fmt.Printf("  %s -mermaid-tree 2 -mermaid-go -go-doc ./my-project\n", exeFile)
+		fmt.Printf("  %s -annotations -annotation-tags NOTE -annotation-blame ./my-project\n", exeFile)
+		fmt.Printf("  %s resolve ./my-project/project.md src/main.go:12-20\n", exeFile)
 		os.Exit(1)
 	}
 
@@ -121,9 +234,115 @@ func main() {
 		}
 	}
 
+	if *submodules != "" {
+		customConfig.Submodules = *submodules
+	}
+	if *lfs != "" {
+		customConfig.LFS = *lfs
+	}
+	customConfig.Limits = customConfig.Limits.override(LimitsConfig{
+		MaxFileBytes:  *maxFileBytes,
+		MaxFileLines:  *maxFileLines,
+		MaxTotalBytes: *maxTotalBytes,
+		MaxFiles:      *maxFiles,
+		Truncate:      *truncate,
+	})
+	if *generated != "" || *minified != "" {
+		policies := map[string]string{}
+		if *generated != "" {
+			policies[categoryGenerated] = *generated
+		}
+		if *minified != "" {
+			policies[categoryMinified] = *minified
+		}
+		customConfig.Generated.Policies = mergedMap(customConfig.Generated.Policies, policies)
+	}
+	if *noRedact {
+		customConfig.Secrets.Disabled = true
+	}
+	if *order != "" {
+		customConfig.Order.Mode = *order
+	}
+	if *orderPriority != "" {
+		for _, pattern := range strings.Split(*orderPriority, ",") {
+			customConfig.Order.Priority = append(customConfig.Order.Priority, strings.TrimSpace(pattern))
+		}
+	}
+	if *outline {
+		customConfig.Outline.Enabled = true
+	}
+	if *strip {
+		customConfig.Strip.Enabled = true
+	}
+	if *stripKeepDocs {
+		customConfig.Strip.KeepDocs = true
+	}
+	customConfig.LineNumbers = customConfig.LineNumbers.override(LineNumbersConfig{
+		Enabled:   *lineNumbers,
+		Width:     *numberWidth,
+		Separator: *numberSep,
+	})
+	if *annotationTags != "" {
+		for _, tag := range strings.Split(*annotationTags, ",") {
+			customConfig.Annotations.Tags = append(customConfig.Annotations.Tags, strings.TrimSpace(tag))
+		}
+	}
+	customConfig.Annotations = customConfig.Annotations.override(AnnotationsConfig{
+		Enabled: *annotations,
+		Blame:   *annotationBy,
+	})
+	if *allowlistPath != "" {
+		customConfig.Secrets.AllowlistFile, err = filepath.Abs(*allowlistPath)
+		if err != nil {
+			log.Fatalf("failed to get absolute path: %v", err)
+		}
+	}
+
+	statsJSONPath := *statsJSON
+	if statsJSONPath != "" {
+		statsJSONPath, err = filepath.Abs(statsJSONPath)
+		if err != nil {
+			log.Fatalf("failed to get absolute path: %v", err)
+		}
+	}
+
 	// Process project
-	processor := NewProcessor(absPath, config, customConfig, *outputFileName, *verbose, *showStats, *noGit)
+	budget := *queryBudget
+	if *queryTokens > 0 && (budget == 0 || *queryTokens*bytesPerToken < budget) {
+		budget = *queryTokens * bytesPerToken
+	}
+
+	processor := NewProcessor(absPath, config, customConfig, Options{
+		outputFileName: *outputFileName,
+		verbose:        *verbose,
+		showStats:      *showStats,
+		noGit:          *noGit,
+		gitFiles:       *gitFiles,
+		gitUntracked:   *gitUntracked,
+		gitInfo:        *gitInfo,
+		failOnSecrets:  *failOnSecrets,
+		goEntries:      goEntries,
+		goNoTests:      *goNoTests,
+		entries:        entries,
+		query:          *query,
+		queryTop:       *queryTop,
+		queryBudget:    budget,
+		symbols:        symbols,
+		statsJSON:      statsJSONPath,
+		deps:           *deps,
+		goDoc:          *goDoc,
+		mermaidTree:    *mermaidTree,
+		mermaidGo:      *mermaidGo,
+	})
 	if err := processor.Process(); err != nil {
+		var secretsErr *SecretsFoundError
+		if errors.As(err, &secretsErr) {
+			for _, finding := range secretsErr.Findings {
+				fmt.Fprintf(os.Stderr, "%s:%d: %s (fingerprint %s)\n", finding.Path, finding.Line, finding.Rule, finding.Fingerprint())
+			}
+			fmt.Fprintf(os.Stderr, "Error: %v, no archive written\n", err)
+			os.Exit(exitSecretsFound)
+		}
 		log.Fatalf("Error processing project: %v", err)
 	}
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Orders of file sections in the archive
+const (
+	orderName  = "name"
+	orderDeps  = "deps"
+	orderSize  = "size"
+	orderMtime = "mtime"
+	orderChurn = "churn"
+)
+
+// OrderConfig controls the order of file sections.
+// Priority lists globs of files written first; "*" marks the place of files matching no glob.
+type OrderConfig struct {
+	Mode     string   `json:"mode,omitempty"`
+	Priority []string `json:"priority,omitempty"`
+}
+
+// Return a copy of the settings with non-empty values of other applied
+func (o OrderConfig) override(other OrderConfig) OrderConfig {
+	if other.Mode != "" {
+		o.Mode = other.Mode
+	}
+	if len(other.Priority) > 0 {
+		o.Priority = other.Priority
+	}
+	return o
+}
+
+// Index of the first priority glob matching the file, files matching none get the index of "*"
+// or are placed after all globs
+func priorityGroup(relPath string, priority []string) int {
+	rest := len(priority)
+	for i, pattern := range priority {
+		if pattern == "*" {
+			rest = i
+			continue
+		}
+		if matchPathGlob(pattern, relPath) {
+			return i
+		}
+	}
+	return rest
+}
+
+// Order files so that imported packages and modules come before the code importing them.
+// Go files are ordered by package, JavaScript, TypeScript and Python files by file.
+// Files without imports keep their relative order. Imports that cannot be loaded are left out and the error
+// of loading them is returned along with the order.
+func orderByDependencies(files []string, root string) ([]string, error) {
+	node := func(path string) string {
+		if strings.HasSuffix(path, ".go") {
+			return filepath.Dir(path)
+		}
+		return path
+	}
+
+	var goDirs, importFiles []string
+	var nodes []string
+	members := make(map[string][]string)
+	for _, path := range files {
+		ext := strings.ToLower(filepath.Ext(path))
+		key := node(path)
+		if _, exists := members[key]; !exists {
+			nodes = append(nodes, key)
+			if ext == ".go" {
+				goDirs = append(goDirs, key)
+			}
+		}
+		members[key] = append(members[key], path)
+		if containsString(jsExtensions, ext) || containsString(pythonExtensions, ext) {
+			importFiles = append(importFiles, path)
+		}
+	}
+
+	var loadErr error
+	edges := make(map[string][]string)
+	if len(goDirs) > 0 {
+		// Packages that cannot be loaded are left out of the graph, the others are still ordered
+		graph, err := loadGoPackageGraph(goDirs, root, true)
+		for dir, deps := range graph.imports {
+			edges[dir] = append(edges[dir], deps...)
+		}
+		loadErr = err
+	}
+	if len(importFiles) > 0 {
+		graph, err := loadImportGraph(importFiles, root)
+		if err == nil {
+			for path, deps := range graph.imports {
+				edges[path] = append(edges[path], deps...)
+			}
+		} else if loadErr == nil {
+			loadErr = err
+		}
+	}
+
+	result := make([]string, 0, len(files))
+	for _, key := range dependencyOrder(edges, nodes) {
+		result = append(result, members[key]...)
+	}
+	return result, loadErr
+}
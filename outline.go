@@ -0,0 +1,329 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// OutlineConfig selects files rendered as declarations only
+type OutlineConfig struct {
+	Enabled  bool     `json:"enabled,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+// Return a copy of the settings with values of other applied; patterns are added to the existing ones
+func (o OutlineConfig) override(other OutlineConfig) OutlineConfig {
+	if other.Enabled {
+		o.Enabled = true
+	}
+	o.Patterns = append(append([]string{}, o.Patterns...), other.Patterns...)
+	return o
+}
+
+// Note under the header of an outlined file
+const outlineNote = "Outline: declarations only, bodies omitted"
+
+// Check if the file should be outlined
+func (o OutlineConfig) matches(relPath string) bool {
+	if o.Enabled {
+		return true
+	}
+	for _, pattern := range o.Patterns {
+		if matchPathGlob(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Text replacing an elided body
+const elidedBody = "{ ... }"
+
+// Languages outlined by braces and by indentation
+var (
+	braceOutlineLanguages = map[string]bool{
+		"c": true, "cpp": true, "objectivec": true, "csharp": true, "java": true, "kotlin": true, "scala": true,
+		"swift": true, "javascript": true, "jsx": true, "typescript": true, "tsx": true, "rust": true, "php": true,
+		"bash": true, "powershell": true,
+	}
+	indentOutlineLanguages = map[string]bool{"python": true, "ruby": true}
+)
+
+// Blocks of these declarations are outlined instead of elided
+var containerRegexp = regexp.MustCompile(
+	`\b(class|interface|struct|enum|trait|namespace|module|object|extension|protocol|union|record)\s+\w|\bimpl\b|extern\s+"C"`)
+
+// Braces of import and export lists are not bodies
+var importListRegexp = regexp.MustCompile(`^\s*(import|export|use)\b[^=(]*$`)
+
+// Definitions whose bodies are elided in indented languages
+var indentDefinitionRegexp = regexp.MustCompile(`^\s*(async\s+)?def\s`)
+
+// Render lines as declarations only. Returns false if the language is not supported.
+func outlineLines(lines []SourceLine, language string) ([]SourceLine, bool) {
+	switch {
+	case language == "go":
+		if result, ok := outlineGo(lines); ok {
+			return result, true
+		}
+		// Code that does not parse is outlined by braces
+		return outlineByBraces(lines, commentSyntax{
+			lineComments:     []string{"//"},
+			blockComments:    [][2]string{{"/*", "*/"}},
+			strings:          []string{`"`, `'`},
+			multilineStrings: []string{"`"},
+		}), true
+	case braceOutlineLanguages[language]:
+		return outlineByBraces(lines, commentSyntaxes[language]), true
+	case indentOutlineLanguages[language]:
+		return outlineByIndent(lines, commentSyntaxes[language], language == "python"), true
+	}
+	return lines, false
+}
+
+func joinLines(lines []SourceLine) string {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.Text
+	}
+	return strings.Join(texts, "\n")
+}
+
+// Outline Go code: package clause, imports, types, constants, variables and
+// function signatures with their doc comments
+func outlineGo(lines []SourceLine) ([]SourceLine, bool) {
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", joinLines(lines), parser.ParseComments)
+	if err != nil {
+		return nil, false
+	}
+
+	var result []SourceLine
+	// Copy lines between two positions, numbers are 1-based lines of the joined text
+	appendLines := func(from, to int) {
+		if len(result) > 0 {
+			// Blank line between declarations, the one of the file if there is one
+			separator := SourceLine{}
+			if from > 1 && strings.TrimSpace(lines[from-2].Text) == "" && lines[from-2].Number > result[len(result)-1].Number {
+				separator = lines[from-2]
+			}
+			result = append(result, separator)
+		}
+		result = append(result, lines[from-1:to]...)
+	}
+
+	start := file.Package
+	if file.Doc != nil {
+		start = file.Doc.Pos()
+	}
+	appendLines(fileSet.Position(start).Line, fileSet.Position(file.Name.End()).Line)
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			start := decl.Pos()
+			if decl.Doc != nil {
+				start = decl.Doc.Pos()
+			}
+			appendLines(fileSet.Position(start).Line, fileSet.Position(decl.End()).Line)
+		case *ast.FuncDecl:
+			start := decl.Pos()
+			if decl.Doc != nil {
+				start = decl.Doc.Pos()
+			}
+			if decl.Body == nil {
+				appendLines(fileSet.Position(start).Line, fileSet.Position(decl.End()).Line)
+				continue
+			}
+			brace := fileSet.Position(decl.Body.Lbrace)
+			appendLines(fileSet.Position(start).Line, brace.Line)
+			last := &result[len(result)-1]
+			last.Text = last.Text[:brace.Column-1] + elidedBody
+		}
+	}
+	return result, true
+}
+
+// Outline code with braced blocks: blocks of classes and similar containers are kept,
+// other blocks like function bodies are elided
+func outlineByBraces(lines []SourceLine, syntax commentSyntax) []SourceLine {
+	text := joinLines(lines)
+	masked := maskSpans(text, syntax)
+
+	var result []SourceLine
+	// Depth of braces and the depth where the elided block ends, -1 if nothing is elided
+	depth, elideUntil := 0, -1
+	var statement strings.Builder
+	offset := 0
+	for _, line := range lines {
+		code := masked[offset : offset+len(line.Text)]
+		offset += len(line.Text) + 1
+
+		if elideUntil < 0 {
+			result = append(result, line)
+		}
+		// Start of the code not yet added to the statement
+		from := 0
+		for i := 0; i < len(code); i++ {
+			switch code[i] {
+			case '{':
+				depth++
+				if elideUntil >= 0 {
+					continue
+				}
+				statement.WriteString(code[from:i])
+				declaration := strings.TrimSpace(statement.String())
+				statement.Reset()
+				from = i + 1
+				if containerRegexp.MatchString(declaration) || importListRegexp.MatchString(declaration) {
+					continue
+				}
+
+				// Cut the line at the body, a body closed on the same line is skipped
+				last := &result[len(result)-1]
+				end := matchingBrace(code, i)
+				if prefix := strings.TrimRight(line.Text[:i], " \t"); prefix != "" {
+					last.Text = prefix + " " + elidedBody
+				} else {
+					last.Text = line.Text[:i] + elidedBody
+				}
+				if end >= 0 {
+					depth--
+					last.Text += line.Text[end+1:]
+					i, from = end, end+1
+					continue
+				}
+				elideUntil = depth - 1
+				i = len(code)
+			case '}', ';':
+				if code[i] == '}' {
+					depth--
+					if depth == elideUntil {
+						elideUntil = -1
+					}
+				}
+				statement.Reset()
+				from = i + 1
+			}
+		}
+		if elideUntil < 0 && from < len(code) {
+			statement.WriteString(code[from:] + "\n")
+		}
+	}
+	return result
+}
+
+// Position of the brace closing the one at start within the same text, -1 if it is not there
+func matchingBrace(code string, start int) int {
+	depth := 0
+	for i := start; i < len(code); i++ {
+		switch code[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Replace string literals and comments with spaces, keeping offsets and line breaks
+func maskSpans(text string, syntax commentSyntax) string {
+	comments, literals := scanComments(text, syntax)
+	spans := literals
+	for _, comment := range comments {
+		spans = append(spans, comment.textSpan)
+	}
+
+	masked := []byte(text)
+	for _, span := range spans {
+		for i := span.start; i < span.end; i++ {
+			if masked[i] != '\n' {
+				masked[i] = ' '
+			}
+		}
+	}
+	return string(masked)
+}
+
+// Outline code structured by indentation: bodies of definitions are replaced by "...",
+// Python docstrings are kept
+func outlineByIndent(lines []SourceLine, syntax commentSyntax, docstrings bool) []SourceLine {
+	text := joinLines(lines)
+	_, literals := scanComments(text, syntax)
+	masked := strings.Split(maskSpans(text, syntax), "\n")
+	starts := make([]int, len(lines))
+	offset := 0
+	for i, line := range lines {
+		starts[i] = offset
+		offset += len(line.Text) + 1
+	}
+
+	var result []SourceLine
+	for i := 0; i < len(lines); i++ {
+		result = append(result, lines[i])
+		if !indentDefinitionRegexp.MatchString(masked[i]) {
+			continue
+		}
+		indent := indentation(lines[i].Text)
+
+		// Signature may span lines until the parentheses are closed
+		parens := strings.Count(masked[i], "(") - strings.Count(masked[i], ")")
+		for parens > 0 && i+1 < len(lines) {
+			i++
+			result = append(result, lines[i])
+			parens += strings.Count(masked[i], "(") - strings.Count(masked[i], ")")
+		}
+
+		// Body is indented deeper, lines holding only strings or comments may have any indentation
+		end := i + 1
+		for end < len(lines) && (strings.TrimSpace(masked[end]) == "" || indentation(lines[end].Text) > indent) {
+			end++
+		}
+		for end > i+1 && strings.TrimSpace(masked[end-1]) == "" {
+			end--
+		}
+		if end == i+1 {
+			continue
+		}
+
+		first := i + 1
+		for strings.TrimSpace(lines[first].Text) == "" {
+			first++
+		}
+		docEnd := first
+		if docstrings {
+			docStart := starts[first] + indentation(lines[first].Text)
+			for _, literal := range literals {
+				if literal.start == docStart {
+					docEnd = sort.SearchInts(starts, literal.end)
+					break
+				}
+			}
+		}
+		result = append(result, lines[first:docEnd]...)
+		if docEnd < end {
+			result = append(result, SourceLine{Text: lines[first].Text[:indentation(lines[first].Text)] + "..."})
+		}
+
+		// Closing line of a Ruby method
+		i = end - 1
+		if end < len(lines) && indentation(lines[end].Text) == indent && strings.TrimSpace(lines[end].Text) == "end" {
+			result = append(result, lines[end])
+			i = end
+		}
+	}
+	return result
+}
+
+// Number of leading whitespace bytes
+func indentation(text string) int {
+	return len(text) - len(strings.TrimLeft(text, " \t"))
+}
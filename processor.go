@@ -2,10 +2,13 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -13,43 +16,116 @@ import (
 
 // Statistics holds processing statistics
 type Statistics struct {
-	ProcessedFiles int
-	SkippedDirs    int
-	TotalSize      int64
-	StartTime      time.Time
+	ProcessedFiles  int
+	SkippedDirs     int
+	LFSPointers     int
+	BinaryFiles     int
+	SkippedByLimits int
+	ExcludedLines   int
+	StrippedBytes   int64
+	OutlinedFiles   int
+	Categories      map[string]int
+	Redactions      []SecretFinding
+	Truncations     []Truncation
+	Files           []FileStats
+	TotalSize       int64
+	ContentSize     int64
+	StartTime       time.Time
 }
 
-type Processor struct {
-	projectPath    string
-	defaultConfig  Config
-	customConfig   Config
+// SourceLine is a line of file content with its number in the original file.
+// Lines added by project2md, like omission markers, have number 0.
+type SourceLine struct {
+	Number int
+	Text   string
+}
+
+// FileSection holds everything written for a single file
+type FileSection struct {
+	relPath  string
+	info     os.FileInfo
+	size     int64
+	language string
+	encoding string
+	content  []byte
+	lines    []SourceLine
+	// Extra lines shown under the file header
+	notes []string
+	// Lines of the original file included in a partial section
+	ranges []lineRange
+	// Tagged comments listed in the Annotations section
+	annotations []Annotation
+	// Content is not written, only the header and notes
+	omitted bool
+}
+
+// Options holds command-line settings of a processing run
+type Options struct {
 	outputFileName string
 	verbose        bool
 	showStats      bool
 	noGit          bool
-	gitIgnore      *GitIgnore
-	stats          *Statistics
-	files          []string
-	writer         *bufio.Writer
+	gitFiles       bool
+	gitUntracked   bool
+	gitInfo        bool
+	failOnSecrets  bool
+	goEntries      []string
+	goNoTests      bool
+	entries        []string
+	query          string
+	queryTop       int
+	queryBudget    int64
+	symbols        []string
+	statsJSON      string
+	deps           bool
+	goDoc          bool
+	mermaidTree    int
+	mermaidGo      bool
+}
+
+type Processor struct {
+	Options
+	projectPath   string
+	defaultConfig Config
+	customConfig  Config
+	gitIgnore     *GitIgnore
+	gitAttributes *GitAttributes
+	repoInfo      *GitInfo
+	fileCommits   map[string]FileCommit
+	repos         []*NestedRepo
+	gitModules    map[string]map[string]struct{}
+	lfsGitDirs    map[string]string
+	secrets       *SecretDetector
+	// Lines of selected symbols by relative path
+	symbolRanges map[string][]lineRange
+	// Manifests and lockfiles found in the tree, archived or not
+	dependencyFiles []string
+	// Markers of generated files
+	generatedMarkers []*regexp.Regexp
+	// Pattern of annotation tags, nil if the Annotations section is not written
+	annotationPattern *regexp.Regexp
+	annotations       []Annotation
+	// Anchors of file sections linked from other sections, by relative path
+	anchors     map[string]string
+	usedAnchors map[string]bool
+	stats       *Statistics
+	files       []string
+	writer      *bufio.Writer
 }
 
 func NewProcessor(
 	projectPath string,
 	defaultConfig Config,
 	customConfig Config,
-	outputFileName string,
-	verbose bool,
-	showStats bool,
-	noGit bool,
+	options Options,
 ) *Processor {
 	return &Processor{
-		projectPath:    projectPath,
-		defaultConfig:  defaultConfig,
-		customConfig:   customConfig,
-		outputFileName: outputFileName,
-		verbose:        verbose,
-		showStats:      showStats,
-		noGit:          noGit,
+		Options:       options,
+		projectPath:   projectPath,
+		defaultConfig: defaultConfig,
+		customConfig:  customConfig,
+		anchors:       make(map[string]string),
+		usedAnchors:   make(map[string]bool),
 	}
 }
 
@@ -126,6 +202,129 @@ func getLanguage(filename string, config Config) string {
 
 // Process project directory and generate archive
 func (p *Processor) Process() error {
+	var err error
+	p.stats = &Statistics{
+		StartTime: time.Now(),
+	}
+
+	switch p.submodulePolicy() {
+	case submodulesSkip, submodulesInclude, submodulesRecurse:
+	default:
+		return fmt.Errorf("unknown submodule policy %q", p.submodulePolicy())
+	}
+
+	switch p.lfsPolicy() {
+	case lfsSkip, lfsAnnotate, lfsResolve:
+	default:
+		return fmt.Errorf("unknown LFS policy %q", p.lfsPolicy())
+	}
+
+	for pattern, encoding := range p.customConfig.Encodings {
+		if normalizeEncoding(encoding) == "" {
+			return fmt.Errorf("unknown encoding %q for %q", encoding, pattern)
+		}
+	}
+
+	switch action := p.binarySettings("").Action; action {
+	case binarySkip, binaryPlaceholder:
+	default:
+		return fmt.Errorf("unknown binary file action %q", action)
+	}
+
+	for category, policy := range p.generatedSettings().Policies {
+		switch policy {
+		case generatedSkip, generatedStub, generatedInclude:
+		default:
+			return fmt.Errorf("unknown policy %q for %s files", policy, category)
+		}
+	}
+	p.generatedMarkers, err = generatedMarkerRegexps(p.generatedSettings().Markers)
+	if err != nil {
+		return err
+	}
+
+	if settings := p.secretsSettings(); !settings.Disabled || p.failOnSecrets {
+		var allowlist map[string]bool
+		if settings.AllowlistFile != "" {
+			allowlistPath := settings.AllowlistFile
+			if !filepath.IsAbs(allowlistPath) {
+				allowlistPath = filepath.Join(p.projectPath, allowlistPath)
+			}
+			allowlist, err = loadSecretsAllowlist(allowlistPath)
+			if err != nil {
+				return err
+			}
+		}
+		p.secrets, err = newSecretDetector(settings, allowlist)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch mode := p.orderSettings().Mode; mode {
+	case "", orderName, orderDeps, orderSize, orderMtime, orderChurn:
+	default:
+		return fmt.Errorf("unknown order %q", mode)
+	}
+
+	switch strategy := p.limitsFor("").Truncate; strategy {
+	case truncateSkip, truncateHead, truncateHeadTail, truncateHeadMarker:
+	default:
+		return fmt.Errorf("unknown truncation strategy %q", strategy)
+	}
+
+	if settings := p.annotationSettings(); settings.Enabled {
+		p.annotationPattern, err = annotationRegexp(settings.Tags)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.gitInfo {
+		p.loadGitInfo()
+	}
+
+	if !p.noGit {
+		p.gitAttributes, err = loadGitAttributes(p.projectPath)
+		if err != nil {
+			return fmt.Errorf("failed to load .gitattributes: %w", err)
+		}
+	}
+
+	// Collect all files first for better organization
+	err = p.findFiles()
+	if err != nil {
+		return fmt.Errorf("error walking directory: %w", err)
+	}
+
+	// Sort files for consistent output
+	sort.Strings(p.files)
+
+	if len(p.goEntries) > 0 || len(p.entries) > 0 {
+		if err := p.selectEntryFiles(); err != nil {
+			return err
+		}
+	}
+
+	if p.query != "" {
+		p.selectByQuery()
+	}
+
+	if len(p.symbols) > 0 {
+		if err := p.selectSymbols(); err != nil {
+			return err
+		}
+	}
+
+	p.orderFiles()
+
+	// Nothing is written if the files contain secrets
+	if p.failOnSecrets {
+		if err := p.checkSecrets(); err != nil {
+			return err
+		}
+	}
+
 	var outputFile string
 	if filepath.IsAbs(p.outputFileName) {
 		outputFile = p.outputFileName
@@ -141,20 +340,31 @@ func (p *Processor) Process() error {
 		_ = file.Close()
 	}()
 
-	p.writer = bufio.NewWriter(file)
+	output := bufio.NewWriter(file)
 	defer func() {
-		_ = p.writer.Flush()
+		_ = output.Flush()
 	}()
 
-	// Load .gitignore patterns
-	err = p.loadGitIgnore()
-	if err != nil {
-		return err
+	var goPackages []*GoPackageDoc
+	if p.goDoc {
+		goPackages = p.loadGoDocs()
 	}
 
-	p.stats = &Statistics{
-		StartTime: time.Now(),
+	if p.gitInfo && p.repoInfo != nil {
+		p.loadFileCommits()
+	}
+
+	// File sections are written to a buffer first, the sections before them only describe archived files
+	var sections bytes.Buffer
+	p.writer = bufio.NewWriter(&sections)
+	err2 := p.processFiles()
+	if err2 != nil {
+		return err2
 	}
+	if err := p.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write file sections: %w", err)
+	}
+	p.writer = output
 
 	// Write header
 	err = p.writeHeader()
@@ -162,19 +372,38 @@ func (p *Processor) Process() error {
 		return err
 	}
 
-	// Collect all files first for better organization
-	err = p.findFiles()
-	if err != nil {
-		return fmt.Errorf("error walking directory: %w", err)
+	if p.deps {
+		if err := p.writeDependencies(); err != nil {
+			return err
+		}
 	}
 
-	// Sort files for consistent output
-	sort.Strings(p.files)
+	if p.mermaidTree > 0 || p.mermaidGo {
+		if err := p.writeDiagrams(); err != nil {
+			return err
+		}
+	}
 
-	// Process files
-	err2 := p.processFiles()
-	if err2 != nil {
-		return err2
+	if p.goDoc {
+		if err := p.writeGoDoc(goPackages); err != nil {
+			return err
+		}
+	}
+
+	if _, err := p.writer.Write(sections.Bytes()); err != nil {
+		return fmt.Errorf("failed to write file sections: %w", err)
+	}
+
+	if p.annotationPattern != nil {
+		if err := p.writeAnnotations(); err != nil {
+			return err
+		}
+	}
+
+	if len(p.stats.Redactions) > 0 {
+		if err := p.writeRedactions(); err != nil {
+			return err
+		}
 	}
 
 	// Write statistics only if showStats is true
@@ -185,6 +414,16 @@ func (p *Processor) Process() error {
 		}
 	}
 
+	if p.statsJSON != "" {
+		if err := p.writeStatsJSON(); err != nil {
+			return err
+		}
+	}
+
+	if len(p.stats.Redactions) > 0 {
+		fmt.Printf("\nRedacted %d secrets, see the Redactions section of the archive\n", len(p.stats.Redactions))
+	}
+
 	fmt.Printf("\nArchive created: %s\n", outputFile)
 	fmt.Printf("Statistics: %d files, %s", p.stats.ProcessedFiles, formatFileSize(p.stats.TotalSize))
 	if p.showStats {
@@ -196,6 +435,105 @@ func (p *Processor) Process() error {
 	return nil
 }
 
+func (p *Processor) writeRedactions() error {
+	if err := writeFileContent(p.writer, "---\n\n## Redactions\n\n"); err != nil {
+		return fmt.Errorf("failed to write redactions header: %w", err)
+	}
+
+	for _, finding := range p.stats.Redactions {
+		if err := writeFileContent(p.writer, "- `%s:%d`: %s\n", finding.Path, finding.Line, finding.Rule); err != nil {
+			return fmt.Errorf("failed to write redaction: %w", err)
+		}
+	}
+
+	if err := writeFileContent(p.writer, "\n"); err != nil {
+		return fmt.Errorf("failed to write redactions: %w", err)
+	}
+	return nil
+}
+
+// Write the index of tagged comments with links to their file sections
+func (p *Processor) writeAnnotations() error {
+	if p.annotationSettings().Blame {
+		p.blameAnnotations()
+	}
+
+	if err := writeFileContent(p.writer, "---\n\n## Annotations\n\n"); err != nil {
+		return fmt.Errorf("failed to write annotations header: %w", err)
+	}
+
+	if len(p.annotations) == 0 {
+		if err := writeFileContent(p.writer, "*No annotations found*\n\n"); err != nil {
+			return fmt.Errorf("failed to write annotations: %w", err)
+		}
+		return nil
+	}
+
+	counts := make(map[string]int)
+	for _, annotation := range p.annotations {
+		counts[annotation.Tag]++
+	}
+	var summary []string
+	for _, tag := range sortedKeys(counts) {
+		summary = append(summary, fmt.Sprintf("%s %d", tag, counts[tag]))
+	}
+	if err := writeFileContent(p.writer, "Found %d annotations: %s\n\n", len(p.annotations), strings.Join(summary, ", ")); err != nil {
+		return fmt.Errorf("failed to write annotations summary: %w", err)
+	}
+
+	for _, annotation := range p.annotations {
+		attribution := ""
+		if annotation.Author != "" {
+			attribution = fmt.Sprintf(" (%s, %s)", annotation.Author, annotation.Date.Format("2006-01-02"))
+		}
+		if err := writeFileContent(
+			p.writer,
+			"- [`%s:%d`](#%s) **%s** %s%s\n",
+			annotation.Path,
+			annotation.Line,
+			p.anchorFor(annotation.Path),
+			annotation.label(),
+			annotation.Text,
+			attribution,
+		); err != nil {
+			return fmt.Errorf("failed to write annotation: %w", err)
+		}
+	}
+
+	if err := writeFileContent(p.writer, "\n"); err != nil {
+		return fmt.Errorf("failed to write annotations: %w", err)
+	}
+	return nil
+}
+
+// Add the author of every annotated line from git blame, in the repository holding the file
+func (p *Processor) blameAnnotations() {
+	blames := make(map[string]map[int]FileCommit)
+	for i, annotation := range p.annotations {
+		blame, loaded := blames[annotation.Path]
+		if !loaded {
+			path := filepath.Join(p.projectPath, filepath.FromSlash(annotation.Path))
+			dir := p.projectPath
+			if repo := p.repoFor(path); repo != nil {
+				dir = repo.Path
+			}
+			relPath, _ := filepath.Rel(dir, path)
+
+			var err error
+			blame, err = loadBlame(dir, relPath)
+			if err != nil && p.verbose {
+				log.Printf("Warning: cannot read git blame of %s: %v", annotation.Path, err)
+			}
+			blames[annotation.Path] = blame
+		}
+
+		if commit, exists := blame[annotation.Line]; exists {
+			p.annotations[i].Author = commit.Author
+			p.annotations[i].Date = commit.Date
+		}
+	}
+}
+
 func (p *Processor) writeStats() error {
 	duration := time.Since(p.stats.StartTime)
 	if err := writeFileContent(p.writer, "---\n\n"); err != nil {
@@ -214,6 +552,77 @@ func (p *Processor) writeStats() error {
 		return fmt.Errorf("failed to write dir count: %w", err)
 	}
 
+	if p.stats.LFSPointers > 0 {
+		if err := writeFileContent(p.writer, "- **Git LFS pointers**: %d\n", p.stats.LFSPointers); err != nil {
+			return fmt.Errorf("failed to write LFS pointer count: %w", err)
+		}
+	}
+
+	if p.stats.BinaryFiles > 0 {
+		if err := writeFileContent(p.writer, "- **Binary files**: %d\n", p.stats.BinaryFiles); err != nil {
+			return fmt.Errorf("failed to write binary file count: %w", err)
+		}
+	}
+
+	categories := make([]string, 0, len(p.stats.Categories))
+	for category := range p.stats.Categories {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		if err := writeFileContent(p.writer, "- **%s files**: %d\n", capitalize(category), p.stats.Categories[category]); err != nil {
+			return fmt.Errorf("failed to write %s file count: %w", category, err)
+		}
+	}
+
+	if p.stats.SkippedByLimits > 0 {
+		if err := writeFileContent(p.writer, "- **Files skipped by limits**: %d\n", p.stats.SkippedByLimits); err != nil {
+			return fmt.Errorf("failed to write skipped file count: %w", err)
+		}
+	}
+
+	if p.stats.ExcludedLines > 0 {
+		if err := writeFileContent(p.writer, "- **Lines excluded by directives**: %d\n", p.stats.ExcludedLines); err != nil {
+			return fmt.Errorf("failed to write excluded line count: %w", err)
+		}
+	}
+
+	if p.stats.OutlinedFiles > 0 {
+		if err := writeFileContent(p.writer, "- **Outlined files**: %d\n", p.stats.OutlinedFiles); err != nil {
+			return fmt.Errorf("failed to write outlined file count: %w", err)
+		}
+	}
+
+	if p.stats.StrippedBytes > 0 {
+		if err := writeFileContent(p.writer, "- **Stripped comments and whitespace**: %s\n", formatFileSize(p.stats.StrippedBytes)); err != nil {
+			return fmt.Errorf("failed to write stripped size: %w", err)
+		}
+	}
+
+	if len(p.stats.Redactions) > 0 {
+		if err := writeFileContent(p.writer, "- **Redacted secrets**: %d\n", len(p.stats.Redactions)); err != nil {
+			return fmt.Errorf("failed to write redaction count: %w", err)
+		}
+	}
+
+	if len(p.stats.Truncations) > 0 {
+		if err := writeFileContent(p.writer, "- **Truncated files**: %d\n", len(p.stats.Truncations)); err != nil {
+			return fmt.Errorf("failed to write truncated file count: %w", err)
+		}
+		for _, truncation := range p.stats.Truncations {
+			if err := writeFileContent(
+				p.writer,
+				"  - `%s`: %s, %d of %d lines included\n",
+				truncation.Path,
+				truncation.Reason,
+				truncation.KeptLines,
+				truncation.OriginalLines,
+			); err != nil {
+				return fmt.Errorf("failed to write truncation: %w", err)
+			}
+		}
+	}
+
 	if err := writeFileContent(p.writer, "- **Total size**: %s\n", formatFileSize(p.stats.TotalSize)); err != nil {
 		return fmt.Errorf("failed to write total size: %w", err)
 	}
@@ -221,83 +630,636 @@ func (p *Processor) writeStats() error {
 	if err := writeFileContent(p.writer, "- **Processing time**: %v\n", duration.Round(time.Millisecond)); err != nil {
 		return fmt.Errorf("failed to write duration: %w", err)
 	}
+
+	if len(p.stats.Files) == 0 {
+		return nil
+	}
+	report := newStatsReport(p.stats.Files)
+	if err := p.writeGroupStats("Languages", "Language", report.Languages, report); err != nil {
+		return err
+	}
+	if err := p.writeGroupStats("Directories", "Directory", report.Directories, report); err != nil {
+		return err
+	}
+	return p.writeLargestFiles(report.Largest)
+}
+
+// Write a table of statistics by language or directory with a total row
+func (p *Processor) writeGroupStats(title, column string, groups map[string]*GroupStats, report *StatsReport) error {
+	if err := writeFileContent(
+		p.writer,
+		"\n### %s\n\n| %s | Files | Lines | Blank | Comment | Code | Size | Tokens |\n|---|---:|---:|---:|---:|---:|---:|---:|\n",
+		title,
+		column,
+	); err != nil {
+		return fmt.Errorf("failed to write %s table header: %w", strings.ToLower(title), err)
+	}
+
+	row := "| %s | %d | %d | %d | %d | %d | %s | %d |\n"
+	for _, name := range groupsByCode(groups) {
+		group := groups[name]
+		if err := writeFileContent(
+			p.writer, row, name, group.Files, group.Lines, group.Blank, group.Comment, group.Code,
+			formatFileSize(group.Bytes), group.Tokens,
+		); err != nil {
+			return fmt.Errorf("failed to write %s statistics: %w", name, err)
+		}
+	}
+	if err := writeFileContent(
+		p.writer, row, "**Total**", report.Files, report.Lines, report.Blank, report.Comment, report.Code,
+		formatFileSize(report.Bytes), report.Tokens,
+	); err != nil {
+		return fmt.Errorf("failed to write %s total: %w", strings.ToLower(title), err)
+	}
+	return nil
+}
+
+func (p *Processor) writeLargestFiles(files []FileStats) error {
+	if err := writeFileContent(
+		p.writer,
+		"\n### Largest Files\n\n| File | Language | Size | Lines | Tokens |\n|---|---|---:|---:|---:|\n",
+	); err != nil {
+		return fmt.Errorf("failed to write largest files header: %w", err)
+	}
+
+	for _, file := range files {
+		if err := writeFileContent(
+			p.writer,
+			"| `%s` | %s | %s | %d | %d |\n",
+			file.Path,
+			file.Language,
+			formatFileSize(file.Bytes),
+			file.Lines,
+			file.Tokens,
+		); err != nil {
+			return fmt.Errorf("failed to write largest file: %w", err)
+		}
+	}
+	return nil
+}
+
+// Write the statistics as JSON to the -stats-json file
+func (p *Processor) writeStatsJSON() error {
+	report := newStatsReport(p.stats.Files)
+	report.SkippedDirs = p.stats.SkippedDirs
+	report.ContentBytes = p.stats.ContentSize
+	report.DurationMs = time.Since(p.stats.StartTime).Milliseconds()
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode statistics: %w", err)
+	}
+	if err := os.WriteFile(p.statsJSON, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write statistics file: %w", err)
+	}
 	return nil
 }
 
 func (p *Processor) processFiles() error {
+	limits := p.limitsFor("")
 	for _, path := range p.files {
-		info, err := os.Stat(path)
-		if err != nil {
+		if limits.MaxFiles > 0 && p.stats.ProcessedFiles >= limits.MaxFiles {
+			p.stats.SkippedByLimits++
 			if p.verbose {
-				log.Printf("Warning: cannot stat file %s: %v", path, err)
+				fmt.Printf("Ignored by file count limit: %s\n", path)
 			}
 			continue
 		}
 
-		// Get relative path
-		relPath, err := filepath.Rel(p.projectPath, path)
-		if err != nil {
-			if p.verbose {
-				log.Printf("Warning: cannot get relative path for %s: %v", path, err)
-			}
+		section := p.readFileSection(path)
+		if section == nil {
 			continue
 		}
-
-		// Read file content
-		content, err := os.ReadFile(path)
-		if err != nil {
-			if p.verbose {
-				log.Printf("Warning: cannot read file %s: %v", relPath, err)
-			}
+		// Lines are counted in the original file, before anything is removed
+		var counts LineCounts
+		if p.showStats || p.statsJSON != "" {
+			counts = countLines(section.lines, section.language)
+		}
+		if !p.applyDirectives(section) || !p.applyGeneratedPolicy(section) {
+			continue
+		}
+		p.extractSymbols(section)
+		p.redactSecrets(section)
+		p.collectAnnotations(section)
+		p.outlineContent(section)
+		p.stripContent(section)
+		if !p.applyLimits(section) {
 			continue
 		}
-
-		// Get language for syntax highlighting
-		language := getLanguage(info.Name(), p.defaultConfig)
 
 		// Write file section
-		err2 := p.writeFileSection(relPath, info, language, content)
+		err2 := p.writeFileSection(section)
 		if err2 != nil {
 			return err2
 		}
 
 		p.stats.ProcessedFiles++
-		p.stats.TotalSize += info.Size()
+		p.annotations = append(p.annotations, section.annotations...)
+		p.stats.TotalSize += section.size
+		p.stats.ContentSize += linesSize(section.lines)
+		p.stats.Files = append(p.stats.Files, FileStats{
+			Path:       filepath.ToSlash(section.relPath),
+			Language:   section.language,
+			Bytes:      section.size,
+			LineCounts: counts,
+			Tokens:     linesSize(section.lines) / bytesPerToken,
+		})
 
 		if p.verbose {
-			fmt.Printf("Processed: %s (%s)\n", relPath, formatFileSize(info.Size()))
+			fmt.Printf("Processed: %s (%s)\n", section.relPath, formatFileSize(section.size))
 		}
 	}
 	return nil
 }
 
-func (p *Processor) writeFileSection(relPath string, info os.FileInfo, language string, content []byte) error {
-	if err := writeFileContent(p.writer, "=== %s ===\n\n", relPath); err != nil {
-		return fmt.Errorf("failed to write file header: %w", err)
+// Read a file and prepare its content for the archive. Returns nil if the file should be skipped.
+func (p *Processor) readFileSection(path string) *FileSection {
+	info, err := os.Stat(path)
+	if err != nil {
+		if p.verbose {
+			log.Printf("Warning: cannot stat file %s: %v", path, err)
+		}
+		return nil
 	}
 
-	if p.showStats {
-		if err := writeFileContent(
-			p.writer,
-			"*Size: %s, Modified: %s*\n\n",
-			formatFileSize(info.Size()),
-			info.ModTime().Format("2006-01-02 15:04:05"),
-		); err != nil {
-			return fmt.Errorf("failed to write file info: %w", err)
+	// Get relative path
+	relPath, err := filepath.Rel(p.projectPath, path)
+	if err != nil {
+		if p.verbose {
+			log.Printf("Warning: cannot get relative path for %s: %v", path, err)
 		}
+		return nil
 	}
 
-	if err := writeFileContent(p.writer, "```%s\n", language); err != nil {
-		return fmt.Errorf("failed to write code block start: %w", err)
+	// Read file content
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if p.verbose {
+			log.Printf("Warning: cannot read file %s: %v", relPath, err)
+		}
+		return nil
 	}
 
-	if err := writeFileContent(p.writer, "%s", string(content)); err != nil {
-		return fmt.Errorf("failed to write file content: %w", err)
+	section := &FileSection{
+		relPath: relPath,
+		info:    info,
+		size:    info.Size(),
+		content: content,
 	}
 
-	if !strings.HasSuffix(string(content), "\n") {
-		if err := writeFileContent(p.writer, "\n"); err != nil {
-			return fmt.Errorf("failed to write newline: %w", err)
+	// Replace Git LFS pointers according to the policy
+	if pointer := parseLFSPointer(content); pointer != nil {
+		p.stats.LFSPointers++
+		if !p.applyLFSPolicy(path, pointer, section) {
+			if p.verbose {
+				fmt.Printf("Ignored Git LFS pointer: %s\n", relPath)
+			}
+			return nil
+		}
+	}
+
+	// Check content of files that are still to be written
+	if !section.omitted {
+		p.decodeContent(section)
+
+		settings := p.binarySettings(info.Name())
+		if isBinary, reason := detectBinary(section.content, settings); isBinary {
+			p.stats.BinaryFiles++
+			if settings.Action == binarySkip {
+				if p.verbose {
+					fmt.Printf("Ignored binary file (%s): %s\n", reason, relPath)
+				}
+				return nil
+			}
+			section.omitted = true
+			section.notes = append(section.notes, fmt.Sprintf("Binary file (%s), content not included", reason))
+		}
+	}
+
+	if !section.omitted {
+		section.lines = splitSourceLines(string(section.content))
+	}
+
+	// Get language for syntax highlighting
+	section.language = p.languageOf(path)
+	return section
+}
+
+// Language of a file by its name, unless set by git attributes
+func (p *Processor) languageOf(path string) string {
+	if lang := p.gitAttributes.attributesFor(path)["linguist-language"]; lang != "" {
+		return linguistLanguage(lang)
+	}
+	return getLanguage(filepath.Base(path), p.defaultConfig)
+}
+
+// Remove regions excluded by inline directives. Returns false if the file should be skipped.
+func (p *Processor) applyDirectives(section *FileSection) bool {
+	if section.omitted {
+		return true
+	}
+
+	lines, removed, ignoreFile := filterDirectiveRegions(section.lines)
+	if ignoreFile {
+		if p.verbose {
+			fmt.Printf("Ignored by directive: %s\n", section.relPath)
+		}
+		return false
+	}
+	section.lines = lines
+	p.stats.ExcludedLines += removed
+	return true
+}
+
+// Detect generated and minified files and apply their policy. Returns false if the file should be skipped.
+func (p *Processor) applyGeneratedPolicy(section *FileSection) bool {
+	if section.omitted {
+		return true
+	}
+
+	settings := p.generatedSettings()
+	category, reason := detectGenerated(section.relPath, section.lines, settings, p.generatedMarkers)
+	if category == "" {
+		return true
+	}
+
+	if p.stats.Categories == nil {
+		p.stats.Categories = make(map[string]int)
+	}
+	p.stats.Categories[category]++
+
+	switch settings.Policies[category] {
+	case generatedSkip:
+		if p.verbose {
+			fmt.Printf("Ignored %s file (%s): %s\n", category, reason, section.relPath)
+		}
+		return false
+	case generatedStub:
+		section.omitted = true
+		section.lines = nil
+		section.notes = append(section.notes, fmt.Sprintf("%s file (%s), content not included", capitalize(category), reason))
+	}
+	return true
+}
+
+// Scan the content of selected files for secrets without writing anything
+func (p *Processor) checkSecrets() error {
+	// Files are read again for the archive, keep skip messages and counters of this pass out of it
+	verbose, stats := p.verbose, p.stats
+	p.verbose, p.stats = false, &Statistics{}
+	defer func() {
+		p.verbose, p.stats = verbose, stats
+	}()
+
+	var findings []SecretFinding
+	for _, path := range p.files {
+		section := p.readFileSection(path)
+		if section == nil || section.omitted || !p.applyDirectives(section) {
+			continue
+		}
+		_, found := p.secrets.redact(section.relPath, section.lines)
+		findings = append(findings, found...)
+	}
+	// Manifests are summarized in the Dependencies section even when they are not archived
+	archived := make(map[string]bool, len(p.files))
+	for _, path := range p.files {
+		archived[path] = true
+	}
+	for _, path := range p.dependencyFiles {
+		if !archived[path] && isManifest(filepath.Base(path)) {
+			findings = append(findings, p.manifestSecrets(path)...)
+		}
+	}
+
+	if len(findings) > 0 {
+		return &SecretsFoundError{Findings: findings}
+	}
+	return nil
+}
+
+// Find secrets in the content of a manifest as it is on disk
+func (p *Processor) manifestSecrets(path string) []SecretFinding {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	_, findings := p.secrets.redact(p.relativePath(path), splitSourceLines(string(content)))
+	return findings
+}
+
+// Replace secrets in the content with placeholders
+func (p *Processor) redactSecrets(section *FileSection) {
+	if p.secrets == nil || section.omitted || p.secretsSettings().Disabled {
+		return
+	}
+
+	lines, findings := p.secrets.redact(section.relPath, section.lines)
+	if len(findings) == 0 {
+		return
+	}
+	section.lines = lines
+	section.notes = append(section.notes, fmt.Sprintf("%d secrets redacted", len(findings)))
+	p.stats.Redactions = append(p.stats.Redactions, findings...)
+	if p.verbose {
+		for _, finding := range findings {
+			fmt.Printf("Redacted %s: %s:%d\n", finding.Rule, finding.Path, finding.Line)
+		}
+	}
+}
+
+// Keep only the lines of the selected symbols
+func (p *Processor) extractSymbols(section *FileSection) {
+	ranges, exists := p.symbolRanges[section.relPath]
+	if !exists || section.omitted {
+		return
+	}
+	section.lines = selectLineRanges(section.lines, ranges)
+	section.ranges = ranges
+}
+
+// Reduce the content to declarations if the file is selected for outlining
+func (p *Processor) outlineContent(section *FileSection) {
+	settings := p.defaultConfig.Outline.override(p.customConfig.Outline)
+	if section.omitted || !settings.matches(section.relPath) {
+		return
+	}
+
+	lines, ok := outlineLines(section.lines, section.language)
+	if !ok {
+		return
+	}
+	section.lines = lines
+	section.notes = append(section.notes, outlineNote)
+	p.stats.OutlinedFiles++
+}
+
+// Remove comments and redundant whitespace from the content
+func (p *Processor) stripContent(section *FileSection) {
+	settings := p.defaultConfig.Strip.override(p.customConfig.Strip)
+	if !settings.Enabled || section.omitted {
+		return
+	}
+
+	size := linesSize(section.lines)
+	section.lines = stripLines(section.lines, section.language, settings)
+	p.stats.StrippedBytes += size - linesSize(section.lines)
+}
+
+// Find tagged comments for the Annotations section
+func (p *Processor) collectAnnotations(section *FileSection) {
+	if p.annotationPattern == nil || section.omitted {
+		return
+	}
+
+	section.annotations = findAnnotations(section.lines, section.language, p.annotationPattern)
+	for i := range section.annotations {
+		section.annotations[i].Path = filepath.ToSlash(section.relPath)
+	}
+}
+
+func (p *Processor) annotationSettings() AnnotationsConfig {
+	return p.defaultConfig.Annotations.override(p.customConfig.Annotations)
+}
+
+func (p *Processor) secretsSettings() SecretsConfig {
+	return p.defaultConfig.Secrets.override(p.customConfig.Secrets)
+}
+
+func (p *Processor) generatedSettings() GeneratedConfig {
+	return p.defaultConfig.Generated.override(p.customConfig.Generated)
+}
+
+// Truncate the file content to the size limits. Returns false if the file should be skipped.
+func (p *Processor) applyLimits(section *FileSection) bool {
+	if section.omitted {
+		return true
+	}
+
+	limits := p.limitsFor(section.relPath)
+	lines, reason := truncateLines(section.lines, limits)
+	if reason != "" && lines == nil {
+		p.stats.SkippedByLimits++
+		if p.verbose {
+			fmt.Printf("Ignored by size limit (%s): %s\n", reason, section.relPath)
+		}
+		return false
+	}
+
+	if limits.MaxTotalBytes > 0 && p.stats.ContentSize+linesSize(lines) > limits.MaxTotalBytes {
+		p.stats.SkippedByLimits++
+		if p.verbose {
+			fmt.Printf("Ignored by total size limit: %s\n", section.relPath)
+		}
+		return false
+	}
+
+	if reason != "" {
+		kept := 0
+		for _, line := range lines {
+			if line.Number > 0 {
+				kept++
+			}
+		}
+		p.stats.Truncations = append(p.stats.Truncations, Truncation{
+			Path:          section.relPath,
+			Reason:        reason,
+			OriginalLines: len(section.lines),
+			KeptLines:     kept,
+		})
+		section.notes = append(section.notes, fmt.Sprintf("Truncated (%s): %d of %d lines included", reason, kept, len(section.lines)))
+		section.lines = lines
+	}
+	return true
+}
+
+// Size limits for a file; an empty path gives the global limits
+func (p *Processor) limitsFor(relPath string) LimitsConfig {
+	limits := p.defaultConfig.Limits.override(p.customConfig.Limits)
+	if relPath == "" {
+		return limits
+	}
+	return limits.forFile(relPath, p.defaultConfig.Limits.Rules).forFile(relPath, p.customConfig.Limits.Rules)
+}
+
+// Resolve or annotate a Git LFS pointer file. Returns false if the file should be skipped.
+func (p *Processor) applyLFSPolicy(path string, pointer *LFSPointer, section *FileSection) bool {
+	switch p.lfsPolicy() {
+	case lfsSkip:
+		return false
+	case lfsResolve:
+		if content, err := os.ReadFile(p.lfsObjectPath(path, pointer)); err == nil {
+			section.content = content
+			section.size = int64(len(content))
+			section.notes = append(section.notes, "Resolved from Git LFS object")
+			return true
+		}
+		if p.verbose {
+			fmt.Printf("Git LFS object is not available: %s\n", section.relPath)
+		}
+	}
+
+	// The size stays that of the pointer file, the object is not archived
+	section.omitted = true
+	section.notes = append(section.notes, fmt.Sprintf(
+		"Git LFS object sha256:%s, %s (content not included)", pointer.OID, formatFileSize(pointer.Size)))
+	return true
+}
+
+// Find the LFS object file for a pointer located at path
+func (p *Processor) lfsObjectPath(path string, pointer *LFSPointer) string {
+	repoPath := p.projectPath
+	if repo := p.repoFor(path); repo != nil {
+		repoPath = repo.Path
+	}
+
+	if p.lfsGitDirs == nil {
+		p.lfsGitDirs = make(map[string]string)
+	}
+	gitDir, exists := p.lfsGitDirs[repoPath]
+	if !exists {
+		var err error
+		gitDir, err = gitCommonDir(repoPath)
+		if err != nil && p.verbose {
+			log.Printf("Warning: cannot find git directory of %s: %v", repoPath, err)
+		}
+		p.lfsGitDirs[repoPath] = gitDir
+	}
+	if gitDir == "" {
+		return ""
+	}
+	return pointer.objectPath(gitDir)
+}
+
+// Convert content to UTF-8 using the configured or detected encoding
+func (p *Processor) decodeContent(section *FileSection) {
+	matched := ""
+	// The longest matching pattern is the most specific one
+	for pattern, name := range p.customConfig.Encodings {
+		if matchPathGlob(pattern, section.relPath) && len(pattern) > len(matched) {
+			matched, section.encoding = pattern, normalizeEncoding(name)
+		}
+	}
+	if matched == "" {
+		section.encoding = detectEncoding(section.content)
+	}
+
+	switch section.encoding {
+	case "", encodingUTF8:
+		section.encoding = ""
+		return
+	}
+
+	content, err := decodeToUTF8(section.content, section.encoding)
+	if err != nil {
+		if p.verbose {
+			log.Printf("Warning: cannot decode %s: %v", section.relPath, err)
+		}
+		section.encoding = ""
+		return
+	}
+	section.content = content
+}
+
+// Binary detection settings for a file, extension specific values override global ones
+func (p *Processor) binarySettings(filename string) BinaryConfig {
+	settings := p.defaultConfig.Binary.override(p.customConfig.Binary)
+
+	ext := fileExtension(filename)
+	if extSettings, exists := p.defaultConfig.Binary.Extensions[ext]; exists {
+		settings = settings.override(extSettings)
+	}
+	if extSettings, exists := p.customConfig.Binary.Extensions[ext]; exists {
+		settings = settings.override(extSettings)
+	}
+	return settings
+}
+
+func (p *Processor) lfsPolicy() string {
+	if p.customConfig.LFS != "" {
+		return p.customConfig.LFS
+	}
+	return p.defaultConfig.LFS
+}
+
+func (p *Processor) writeFileSection(section *FileSection) error {
+	relPath, info := section.relPath, section.info
+	header := relPath
+	if len(section.ranges) > 0 {
+		header += " (lines " + formatLineRanges(section.ranges) + ")"
+	}
+	if _, linked := p.anchors[filepath.ToSlash(relPath)]; linked || len(section.annotations) > 0 {
+		if err := writeFileContent(p.writer, "<a id=\"%s\"></a>\n\n", p.anchorFor(relPath)); err != nil {
+			return fmt.Errorf("failed to write file anchor: %w", err)
+		}
+	}
+	if err := writeFileContent(p.writer, "=== %s ===\n\n", header); err != nil {
+		return fmt.Errorf("failed to write file header: %w", err)
+	}
+
+	if p.showStats {
+		encoding := ""
+		if section.encoding != "" {
+			encoding = ", Encoding: " + section.encoding
+		}
+		if err := writeFileContent(
+			p.writer,
+			"*Size: %s, Modified: %s%s*\n\n",
+			formatFileSize(section.size),
+			info.ModTime().Format("2006-01-02 15:04:05"),
+			encoding,
+		); err != nil {
+			return fmt.Errorf("failed to write file info: %w", err)
+		}
+	}
+
+	if repo := p.repoFor(filepath.Join(p.projectPath, relPath)); repo != nil {
+		kind := "Nested repository"
+		if repo.Submodule {
+			kind = "Submodule"
+		}
+		repoRelPath, _ := filepath.Rel(p.projectPath, repo.Path)
+		if err := writeFileContent(p.writer, "*%s: `%s`*\n\n", kind, repoRelPath); err != nil {
+			return fmt.Errorf("failed to write repository info: %w", err)
+		}
+	}
+
+	if commit, exists := p.fileCommits[relPath]; exists {
+		if err := writeFileContent(
+			p.writer,
+			"*Last commit: %s by %s on %s*\n\n",
+			shortHash(commit.Hash),
+			commit.Author,
+			commit.Date.Format("2006-01-02 15:04:05"),
+		); err != nil {
+			return fmt.Errorf("failed to write commit info: %w", err)
+		}
+	}
+
+	for _, note := range section.notes {
+		if err := writeFileContent(p.writer, "*%s*\n\n", note); err != nil {
+			return fmt.Errorf("failed to write file note: %w", err)
+		}
+	}
+
+	if section.omitted {
+		return nil
+	}
+
+	if err := writeFileContent(p.writer, "```%s\n", section.language); err != nil {
+		return fmt.Errorf("failed to write code block start: %w", err)
+	}
+
+	if settings := p.lineNumberSettings(); settings.Enabled {
+		for _, text := range numberLines(section.lines, settings) {
+			if err := writeFileContent(p.writer, "%s\n", text); err != nil {
+				return fmt.Errorf("failed to write file content: %w", err)
+			}
+		}
+	} else {
+		for _, line := range section.lines {
+			if err := writeFileContent(p.writer, "%s\n", line.Text); err != nil {
+				return fmt.Errorf("failed to write file content: %w", err)
+			}
 		}
 	}
 
@@ -307,6 +1269,10 @@ func (p *Processor) writeFileSection(relPath string, info os.FileInfo, language
 	return nil
 }
 
+func (p *Processor) lineNumberSettings() LineNumbersConfig {
+	return p.defaultConfig.LineNumbers.override(p.customConfig.LineNumbers)
+}
+
 func (p *Processor) writeHeader() error {
 	if err := writeFileContent(p.writer, "# Code Archive: %s\n\n", filepath.Base(p.projectPath)); err != nil {
 		return fmt.Errorf("failed to write header: %w", err)
@@ -316,6 +1282,24 @@ func (p *Processor) writeHeader() error {
 		return fmt.Errorf("failed to write path: %w", err)
 	}
 
+	if p.repoInfo != nil {
+		if err := p.writeGitInfo(); err != nil {
+			return err
+		}
+	}
+
+	if settings := p.lineNumberSettings(); settings.Enabled {
+		if err := writeFileContent(p.writer, "%s%q\n", lineNumbersHeader, settings.Separator); err != nil {
+			return fmt.Errorf("failed to write line numbers info: %w", err)
+		}
+	}
+
+	if p.defaultConfig.Strip.override(p.customConfig.Strip).Enabled {
+		if err := writeFileContent(p.writer, "%s\n", strippedHeader); err != nil {
+			return fmt.Errorf("failed to write strip info: %w", err)
+		}
+	}
+
 	if err := writeFileContent(p.writer, "Generated at: %s\n\n", time.Now().Format("2006-01-02 15:04:05")); err != nil {
 		return fmt.Errorf("failed to write timestamp: %w", err)
 	}
@@ -326,6 +1310,248 @@ func (p *Processor) writeHeader() error {
 	return nil
 }
 
+// Write the Dependencies section summarizing the manifests and lockfiles of the project
+func (p *Processor) writeDependencies() error {
+	manifests, errs := loadManifests(p.dependencyFiles)
+	if p.verbose {
+		for _, err := range errs {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	if len(manifests) == 0 {
+		return nil
+	}
+
+	text := formatDependencies(manifests, p.relativePath)
+	if p.secrets != nil && !p.secretsSettings().Disabled {
+		archived := make(map[string]bool, len(p.files))
+		for _, path := range p.files {
+			archived[path] = true
+		}
+		for _, manifest := range manifests {
+			findings := p.manifestSecrets(manifest.Path)
+			for _, finding := range findings {
+				text = strings.ReplaceAll(text, finding.Secret, "[REDACTED:"+finding.Rule+"]")
+			}
+			// Findings in archived manifests are recorded with their file sections
+			if !archived[manifest.Path] {
+				p.stats.Redactions = append(p.stats.Redactions, findings...)
+			}
+		}
+	}
+	if err := writeFileContent(p.writer, "%s", text); err != nil {
+		return fmt.Errorf("failed to write dependencies: %w", err)
+	}
+	return nil
+}
+
+// Write Mermaid diagrams of the directories and of the imports between Go packages of the archived files
+func (p *Processor) writeDiagrams() error {
+	// Diagrams show the files of the sections written
+	relPaths := make([]string, len(p.stats.Files))
+	for i, file := range p.stats.Files {
+		relPaths[i] = file.Path
+	}
+
+	var diagrams strings.Builder
+	if p.mermaidTree > 0 {
+		diagrams.WriteString("### Directory Structure\n\n")
+		diagrams.WriteString(formatDirectoryDiagram(filepath.Base(p.projectPath), relPaths, p.mermaidTree))
+	}
+
+	if p.mermaidGo {
+		var dirs []string
+		seen := make(map[string]bool)
+		for _, relPath := range relPaths {
+			dir := filepath.Dir(filepath.Join(p.projectPath, filepath.FromSlash(relPath)))
+			if filepath.Ext(relPath) == ".go" && !strings.HasSuffix(relPath, "_test.go") && !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+
+		// Packages that cannot be read are left out of the graph
+		graph, err := loadGoPackageGraph(dirs, p.projectPath, false)
+		if err != nil && p.verbose {
+			log.Printf("Warning: cannot read all Go package imports: %v", err)
+		}
+		if len(graph.files) > 0 {
+			label := func(dir string) string {
+				return goImportPath(dir, p.projectPath)
+			}
+			diagrams.WriteString("### Go Package Dependencies\n\n")
+			diagrams.WriteString(formatPackageDiagram(graph.imports, sortedKeys(graph.files), label))
+		}
+	}
+
+	if diagrams.Len() == 0 {
+		return nil
+	}
+	if err := writeFileContent(p.writer, "## Diagrams\n\n%s---\n\n", diagrams.String()); err != nil {
+		return fmt.Errorf("failed to write diagrams: %w", err)
+	}
+	return nil
+}
+
+// Summarize the exported API of the archived Go packages, test files are left out. With -symbol only the
+// selected declarations are kept. Files declaring them get anchors, so that their sections can be linked.
+func (p *Processor) loadGoDocs() []*GoPackageDoc {
+	dirs := make(map[string]map[string][]byte)
+	for _, path := range p.files {
+		if filepath.Ext(path) != ".go" || strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		if source := p.goDocSource(path); source != nil {
+			if dirs[filepath.Dir(path)] == nil {
+				dirs[filepath.Dir(path)] = make(map[string][]byte)
+			}
+			dirs[filepath.Dir(path)][path] = source
+		}
+	}
+
+	inSymbols := func(path string, line int) bool {
+		ranges, exists := p.symbolRanges[filepath.FromSlash(path)]
+		if !exists {
+			return true
+		}
+		for _, lines := range ranges {
+			if line >= lines.start && line <= lines.end {
+				return true
+			}
+		}
+		return false
+	}
+
+	var packages []*GoPackageDoc
+	for _, dir := range sortedKeys(dirs) {
+		docs, err := loadGoPackageDocs(dir, dirs[dir], p.projectPath)
+		if err != nil {
+			if p.verbose {
+				log.Printf("Warning: cannot read Go documentation of %s: %v", p.relativePath(dir), err)
+			}
+			continue
+		}
+		for _, doc := range docs {
+			doc.filter(inSymbols)
+		}
+		packages = append(packages, docs...)
+	}
+	for _, pkg := range packages {
+		for _, path := range pkg.paths() {
+			p.anchorFor(path)
+		}
+	}
+	return packages
+}
+
+// Write the Go package summaries, linking the declarations of the files that have been archived
+func (p *Processor) writeGoDoc(packages []*GoPackageDoc) error {
+	if len(packages) == 0 {
+		return nil
+	}
+
+	archived := make(map[string]bool, len(p.stats.Files))
+	for _, file := range p.stats.Files {
+		archived[file.Path] = true
+	}
+	anchor := func(relPath string) string {
+		if !archived[relPath] {
+			return ""
+		}
+		return p.anchorFor(relPath)
+	}
+	if err := writeFileContent(p.writer, "%s", formatGoPackageDocs(packages, anchor)); err != nil {
+		return fmt.Errorf("failed to write Go documentation: %w", err)
+	}
+	return nil
+}
+
+// Content of a Go file as it is archived, with directives, policies for binary and generated files and secret
+// redaction applied. Returns nil if the file is left out or its content is not included.
+func (p *Processor) goDocSource(path string) []byte {
+	// Files are read again for the archive, keep skip messages and counters of this pass out of it
+	verbose, stats := p.verbose, p.stats
+	p.verbose, p.stats = false, &Statistics{}
+	defer func() {
+		p.verbose, p.stats = verbose, stats
+	}()
+
+	section := p.readFileSection(path)
+	if section == nil || !p.applyDirectives(section) || !p.applyGeneratedPolicy(section) {
+		return nil
+	}
+	p.redactSecrets(section)
+	if section.omitted {
+		return nil
+	}
+	return goSourceText(section.lines)
+}
+
+func (p *Processor) writeGitInfo() error {
+	if err := writeFileContent(p.writer, "Repository: `%s`\n", p.repoInfo.Root); err != nil {
+		return fmt.Errorf("failed to write repository: %w", err)
+	}
+
+	if err := writeFileContent(p.writer, "Branch: %s\n", p.repoInfo.Branch); err != nil {
+		return fmt.Errorf("failed to write branch: %w", err)
+	}
+
+	if p.repoInfo.Commit != "" {
+		state := "clean"
+		if p.repoInfo.Dirty {
+			state = "dirty"
+		}
+		if err := writeFileContent(p.writer, "Commit: %s (%s)\n", p.repoInfo.Commit, state); err != nil {
+			return fmt.Errorf("failed to write commit: %w", err)
+		}
+	}
+
+	if p.repoInfo.Remote != "" {
+		if err := writeFileContent(p.writer, "Remote: %s\n", p.repoInfo.Remote); err != nil {
+			return fmt.Errorf("failed to write remote: %w", err)
+		}
+	}
+	return nil
+}
+
+// Load repository details; the archive is still created when git is not available
+func (p *Processor) loadGitInfo() {
+	var err error
+	p.repoInfo, err = loadGitInfo(p.projectPath)
+	if err != nil {
+		if p.verbose {
+			log.Printf("Warning: cannot read git repository info: %v", err)
+		}
+		return
+	}
+}
+
+// Load the last commit of every file, including files of nested repositories
+func (p *Processor) loadFileCommits() {
+	var err error
+	p.fileCommits, err = loadFileCommits(p.projectPath)
+	if err != nil {
+		if p.verbose {
+			log.Printf("Warning: cannot read git history: %v", err)
+		}
+		p.fileCommits = make(map[string]FileCommit)
+	}
+
+	for _, repo := range p.repos {
+		commits, err := loadFileCommits(repo.Path)
+		if err != nil {
+			if p.verbose {
+				log.Printf("Warning: cannot read git history of %s: %v", repo.Path, err)
+			}
+			continue
+		}
+		repoRelPath, _ := filepath.Rel(p.projectPath, repo.Path)
+		for name, commit := range commits {
+			p.fileCommits[filepath.Join(repoRelPath, name)] = commit
+		}
+	}
+}
+
 func (p *Processor) loadGitIgnore() error {
 	if p.noGit {
 		p.gitIgnore = &GitIgnore{}
@@ -341,6 +1567,23 @@ func (p *Processor) loadGitIgnore() error {
 
 func (p *Processor) findFiles() error {
 	clear(p.files)
+	if p.gitFiles {
+		files, err := gitListFiles(p.projectPath, p.gitUntracked)
+		if err == nil {
+			// git has already applied its ignore rules
+			p.gitIgnore = &GitIgnore{}
+			return p.addGitFiles(p.projectPath, files, map[string]bool{p.projectPath: true})
+		}
+		if p.verbose {
+			log.Printf("Warning: cannot list git files, walking directory instead: %v", err)
+		}
+	}
+
+	// Load .gitignore patterns
+	if err := p.loadGitIgnore(); err != nil {
+		return err
+	}
+
 	err := filepath.Walk(p.projectPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			if p.verbose {
@@ -360,8 +1603,352 @@ func (p *Processor) findFiles() error {
 	return err
 }
 
+// Keep only the files most relevant to the query, best first
+func (p *Processor) selectByQuery() {
+	index := newBM25Index()
+	for _, path := range p.files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if p.verbose {
+				log.Printf("Warning: cannot read file %s: %v", path, err)
+			}
+			continue
+		}
+		relPath := p.relativePath(path)
+		if isBinary, _ := detectBinary(content, p.binarySettings(relPath)); isBinary {
+			continue
+		}
+		index.add(path, relPath, int64(len(content)), string(content))
+	}
+
+	ranked := index.rank(p.query)
+	selected := selectRanked(ranked, p.queryTop, p.queryBudget)
+	p.files = p.files[:0]
+	for _, file := range selected {
+		p.files = append(p.files, file.Path)
+	}
+
+	if p.verbose {
+		fmt.Printf("Files matching query %q: %d, selected: %d\n", p.query, len(ranked), len(selected))
+		for _, file := range ranked {
+			status := "skipped"
+			if containsString(p.files, file.Path) {
+				status = "selected"
+			}
+			fmt.Printf("  %8.3f %-8s %s\n", file.Score, status, p.relativePath(file.Path))
+		}
+	}
+}
+
+// Keep only the files declaring the selected symbols and find the lines of their declarations
+func (p *Processor) selectSymbols() error {
+	symbols := make([][]string, len(p.symbols))
+	for i, symbol := range p.symbols {
+		symbols[i] = parseSymbol(symbol)
+	}
+	found := make([]bool, len(symbols))
+	ranges := make(map[string][]lineRange)
+
+	// Go declarations are looked up per package, their types may be in other files of the package
+	var goDirs []string
+	goPackages := make(map[string][]string)
+	for _, path := range p.files {
+		language := p.languageOf(path)
+		if language == "go" {
+			dir := filepath.Dir(path)
+			if _, exists := goPackages[dir]; !exists {
+				goDirs = append(goDirs, dir)
+			}
+			goPackages[dir] = append(goPackages[dir], path)
+			continue
+		}
+		if _, exists := definitionPatterns[language]; !exists {
+			continue
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if p.verbose {
+				log.Printf("Warning: cannot read file %s: %v", path, err)
+			}
+			continue
+		}
+		module := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if fileRanges := definitionRanges(splitSourceLines(string(content)), language, module, symbols, found); len(fileRanges) > 0 {
+			ranges[path] = fileRanges
+		}
+	}
+	for _, dir := range goDirs {
+		for path, fileRanges := range goSymbolRanges(goPackages[dir], symbols, found) {
+			ranges[path] = fileRanges
+		}
+	}
+
+	for i, ok := range found {
+		if !ok {
+			return fmt.Errorf("symbol %q not found", p.symbols[i])
+		}
+	}
+
+	p.symbolRanges = make(map[string][]lineRange, len(ranges))
+	files := p.files[:0]
+	for _, path := range p.files {
+		if fileRanges, exists := ranges[path]; exists {
+			files = append(files, path)
+			relPath := p.relativePath(path)
+			p.symbolRanges[relPath] = fileRanges
+			if p.verbose {
+				fmt.Printf("Symbols in %s: lines %s\n", relPath, formatLineRanges(fileRanges))
+			}
+		}
+	}
+	p.files = files
+	return nil
+}
+
+func (p *Processor) orderSettings() OrderConfig {
+	return p.defaultConfig.Order.override(p.customConfig.Order)
+}
+
+// Arrange the files in the configured order, then move files matching priority globs
+func (p *Processor) orderFiles() {
+	settings := p.orderSettings()
+	switch settings.Mode {
+	case orderName:
+		sort.Strings(p.files)
+	case orderDeps:
+		files, err := orderByDependencies(p.files, p.projectPath)
+		if err != nil && p.verbose {
+			log.Printf("Warning: cannot load all imports for ordering: %v", err)
+		}
+		p.files = files
+	case orderSize, orderMtime:
+		infos := make(map[string]os.FileInfo, len(p.files))
+		for _, path := range p.files {
+			if info, err := os.Stat(path); err == nil {
+				infos[path] = info
+			}
+		}
+		sort.SliceStable(p.files, func(i, j int) bool {
+			a, b := infos[p.files[i]], infos[p.files[j]]
+			if a == nil || b == nil {
+				return a != nil
+			}
+			// Smaller files first, so that more of them fit into the limits; recent changes first
+			if settings.Mode == orderSize {
+				return a.Size() < b.Size()
+			}
+			return a.ModTime().After(b.ModTime())
+		})
+	case orderChurn:
+		churn, err := loadFileChurn(p.projectPath)
+		if err != nil && p.verbose {
+			log.Printf("Warning: cannot load git history for ordering: %v", err)
+		}
+		sort.SliceStable(p.files, func(i, j int) bool {
+			return churn[p.relativePath(p.files[i])] > churn[p.relativePath(p.files[j])]
+		})
+	}
+
+	if len(settings.Priority) > 0 {
+		sort.SliceStable(p.files, func(i, j int) bool {
+			return priorityGroup(p.relativePath(p.files[i]), settings.Priority) <
+				priorityGroup(p.relativePath(p.files[j]), settings.Priority)
+		})
+	}
+}
+
+// Path of a file relative to the project, the path itself if it is outside
+func (p *Processor) relativePath(path string) string {
+	if relPath, err := filepath.Rel(p.projectPath, path); err == nil {
+		return relPath
+	}
+	return path
+}
+
+// Anchor of the section of a file, created when the file is first linked
+func (p *Processor) anchorFor(relPath string) string {
+	relPath = filepath.ToSlash(relPath)
+	if anchor, exists := p.anchors[relPath]; exists {
+		return anchor
+	}
+	anchor := fileAnchor(relPath, p.usedAnchors)
+	p.anchors[relPath] = anchor
+	return anchor
+}
+
+// Restrict the files to those reachable from the entry points, dependencies first
+func (p *Processor) selectEntryFiles() error {
+	selected := make(map[string]bool, len(p.files))
+	for _, path := range p.files {
+		selected[path] = true
+	}
+
+	var reachable []string
+	if len(p.goEntries) > 0 {
+		files, err := p.goEntryFiles()
+		if err != nil {
+			return err
+		}
+		reachable = append(reachable, files...)
+	}
+	if len(p.entries) > 0 {
+		files, err := p.importedFiles()
+		if err != nil {
+			return err
+		}
+		reachable = append(reachable, files...)
+	}
+
+	var files []string
+	for _, path := range reachable {
+		if selected[path] {
+			files = append(files, path)
+			// Files reachable from several entries are written once
+			selected[path] = false
+		}
+	}
+
+	if p.verbose {
+		fmt.Printf("Files reachable from entries: %d\n", len(files))
+	}
+	p.files = files
+	return nil
+}
+
+// Files of Go packages reachable from the entry packages, dependencies first
+func (p *Processor) goEntryFiles() ([]string, error) {
+	entries := make([]string, 0, len(p.goEntries))
+	for _, entry := range p.goEntries {
+		dir := p.entryPath(entry)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("go entry %s is not a package directory", entry)
+		}
+		entries = append(entries, dir)
+	}
+
+	graph, err := loadGoPackageGraph(entries, p.projectPath, !p.goNoTests)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Go imports: %w", err)
+	}
+
+	var files []string
+	packages := graph.packageOrder()
+	for _, module := range graph.modules {
+		for _, dir := range packages {
+			if dir == module.Dir || isSubPath(module.Dir, dir) {
+				files = append(files, filepath.Join(module.Dir, "go.mod"))
+				break
+			}
+		}
+	}
+	for _, dir := range packages {
+		files = append(files, graph.files[dir]...)
+	}
+
+	if p.verbose {
+		fmt.Printf("Go packages reachable from entries: %d\n", len(packages))
+	}
+	return files, nil
+}
+
+// Files reachable by JavaScript, TypeScript and Python imports from the entry files, dependencies first
+func (p *Processor) importedFiles() ([]string, error) {
+	entries := make([]string, 0, len(p.entries))
+	for _, entry := range p.entries {
+		path := p.entryPath(entry)
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			return nil, fmt.Errorf("entry %s is not a file", entry)
+		}
+		entries = append(entries, path)
+	}
+
+	graph, err := loadImportGraph(entries, p.projectPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load imports: %w", err)
+	}
+	return graph.fileOrder(), nil
+}
+
+// Entry points are relative to the project directory
+func (p *Processor) entryPath(entry string) string {
+	if !filepath.IsAbs(entry) {
+		entry = filepath.Join(p.projectPath, entry)
+	}
+	return filepath.Clean(entry)
+}
+
+// Apply project2md rules to the files listed by git below basePath
+func (p *Processor) addGitFiles(basePath string, files []string, allowedDirs map[string]bool) error {
+	for _, name := range files {
+		path := filepath.Join(basePath, name)
+		if !p.isDirTreeAllowed(filepath.Dir(path), allowedDirs) {
+			continue
+		}
+
+		info, err := os.Lstat(path)
+		if err != nil {
+			// Deleted files stay in the index until the deletion is staged
+			if p.verbose {
+				log.Printf("Warning: cannot stat file %s: %v", name, err)
+			}
+			continue
+		}
+		// Submodules and nested repositories are listed as directories
+		if info.IsDir() {
+			if err := p.addNestedRepoFiles(path, allowedDirs); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := p.checkFileShouldBeProcessed(path, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List files of a nested repository with its own git ls-files
+func (p *Processor) addNestedRepoFiles(path string, allowedDirs map[string]bool) error {
+	if !p.isDirTreeAllowed(path, allowedDirs) {
+		return nil
+	}
+	// Submodules that are not checked out have no repository to list
+	if repo := p.repoFor(path); repo == nil || repo.Path != path {
+		return nil
+	}
+
+	files, err := gitListFiles(path, p.gitUntracked)
+	if err != nil {
+		if p.verbose {
+			log.Printf("Warning: cannot list git files of %s: %v", path, err)
+		}
+		return nil
+	}
+	return p.addGitFiles(path, files, allowedDirs)
+}
+
+// Check the directory and all its parents up to the project root against skip rules
+func (p *Processor) isDirTreeAllowed(dir string, allowedDirs map[string]bool) bool {
+	if allowed, exists := allowedDirs[dir]; exists {
+		return allowed
+	}
+
+	allowed := p.isDirTreeAllowed(filepath.Dir(dir), allowedDirs)
+	if allowed {
+		info, err := os.Lstat(dir)
+		allowed = err == nil && p.checkDirAllowed(dir, info) == nil
+	}
+	allowedDirs[dir] = allowed
+	return allowed
+}
+
 func (p *Processor) checkFileShouldBeProcessed(path string, info os.FileInfo) error {
 	filePath := info.Name()
+	if p.deps && isDependencyFile(filePath) {
+		p.dependencyFiles = append(p.dependencyFiles, path)
+	}
 	relPath, _ := filepath.Rel(p.projectPath, path)
 	shouldProcess := shouldProcessFile(relPath, p.customConfig)
 	switch shouldProcess {
@@ -376,12 +1963,22 @@ func (p *Processor) checkFileShouldBeProcessed(path string, info os.FileInfo) er
 			}
 			return nil
 		}
-		if !p.noGit && p.gitIgnore.isMatchPattern(filePath, false) {
+		if !p.noGit && p.gitIgnore.isMatchPattern(path, false) {
 			if p.verbose {
 				fmt.Printf("Ignored by .gitignore: %s\n", filePath)
 			}
 			return nil
 		}
+		switch action, attr := p.checkGitAttributes(path); action {
+		case attrActionSkip:
+			if p.verbose {
+				fmt.Printf("Ignored by .gitattributes (%s): %s\n", attr, filePath)
+			}
+			return nil
+		case attrActionInclude:
+			p.files = append(p.files, path)
+			return nil
+		}
 		shouldProcess = shouldProcessFile(relPath, p.defaultConfig)
 		switch shouldProcess {
 		case -1:
@@ -405,7 +2002,43 @@ func (p *Processor) checkFileShouldBeProcessed(path string, info os.FileInfo) er
 	return nil
 }
 
+// Find the configured action for attributes set on the file.
+// Skipping wins over including.
+func (p *Processor) checkGitAttributes(path string) (action, attr string) {
+	attrs := p.gitAttributes.attributesFor(path)
+	for _, name := range gitAttributeActions {
+		if attrs[name] != "true" {
+			continue
+		}
+		switch p.gitAttributeAction(name) {
+		case attrActionSkip:
+			return attrActionSkip, name
+		case attrActionInclude:
+			action, attr = attrActionInclude, name
+		}
+	}
+	return action, attr
+}
+
+func (p *Processor) gitAttributeAction(attr string) string {
+	if action, exists := p.customConfig.GitAttributes[attr]; exists {
+		return action
+	}
+	return p.defaultConfig.GitAttributes[attr]
+}
+
 func (p *Processor) checkDirAllowed(path string, info os.FileInfo) error {
+	// Check nested git repositories against the submodule policy
+	if path != p.projectPath {
+		if repo := p.detectNestedRepo(path); repo != nil && !p.isNestedRepoAllowed(repo) {
+			if p.verbose {
+				fmt.Printf("Skipping nested repository: %s\n", path)
+			}
+			p.stats.SkippedDirs++
+			return filepath.SkipDir
+		}
+	}
+
 	// Check customConfig first
 	skip, exists := shouldSkipDir(info.Name(), p.customConfig)
 
@@ -439,3 +2072,65 @@ func (p *Processor) checkDirAllowed(path string, info os.FileInfo) error {
 	}
 	return nil
 }
+
+func (p *Processor) submodulePolicy() string {
+	if p.customConfig.Submodules != "" {
+		return p.customConfig.Submodules
+	}
+	return p.defaultConfig.Submodules
+}
+
+// Register path if it is the root of a nested git repository
+func (p *Processor) detectNestedRepo(path string) *NestedRepo {
+	isRepo, isGitFile := detectGitRepo(path)
+	if !isRepo {
+		return nil
+	}
+
+	repo := &NestedRepo{Path: path, Submodule: isGitFile, Depth: 1}
+	parentRoot := p.projectPath
+	if parent := p.repoFor(path); parent != nil {
+		repo.Depth = parent.Depth + 1
+		parentRoot = parent.Path
+	}
+
+	// Old-style submodules keep their .git directory inside the work tree
+	if !repo.Submodule {
+		if p.gitModules == nil {
+			p.gitModules = make(map[string]map[string]struct{})
+		}
+		modules, exists := p.gitModules[parentRoot]
+		if !exists {
+			modules = loadGitModules(parentRoot)
+			p.gitModules[parentRoot] = modules
+		}
+		_, repo.Submodule = modules[path]
+	}
+
+	p.repos = append(p.repos, repo)
+	return repo
+}
+
+func (p *Processor) isNestedRepoAllowed(repo *NestedRepo) bool {
+	switch p.submodulePolicy() {
+	case submodulesSkip:
+		return false
+	case submodulesInclude:
+		return repo.Depth == 1
+	}
+	return true
+}
+
+// Find the innermost nested repository containing path
+func (p *Processor) repoFor(path string) *NestedRepo {
+	var result *NestedRepo
+	for _, repo := range p.repos {
+		if result != nil && len(repo.Path) <= len(result.Path) {
+			continue
+		}
+		if path == repo.Path || isSubPath(repo.Path, path) {
+			result = repo
+		}
+	}
+	return result
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// Path terms describe the whole file and count more than terms of the content
+	pathTermWeight = 3
+	// Rough number of bytes per token of source code
+	bytesPerToken = 4
+)
+
+// Words of text: letters and digits, underscores and punctuation separate them
+var wordRegexp = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// Split text into lowercase terms. Identifiers are split on camelCase and
+// also kept as a whole, so "RateLimiter" gives "ratelimiter", "rate" and "limiter".
+func tokenize(text string) []string {
+	var terms []string
+	for _, word := range wordRegexp.FindAllString(text, -1) {
+		parts := splitIdentifier(word)
+		if len(parts) > 1 {
+			terms = append(terms, stemTerm(strings.ToLower(word)))
+		}
+		for _, part := range parts {
+			const minTermLength = 2
+			if len(part) >= minTermLength {
+				terms = append(terms, stemTerm(strings.ToLower(part)))
+			}
+		}
+	}
+	return terms
+}
+
+// Reduce plural forms, so that "limiters" matches "limiter"
+func stemTerm(term string) string {
+	const minStemLength = 4
+	switch {
+	case len(term) < minStemLength || strings.HasSuffix(term, "ss"):
+		return term
+	case strings.HasSuffix(term, "ies"):
+		return term[:len(term)-3] + "y"
+	case strings.HasSuffix(term, "s"):
+		return term[:len(term)-1]
+	}
+	return term
+}
+
+// Split a camelCase or PascalCase identifier, keeping runs of capitals like "HTTP" together
+func splitIdentifier(word string) []string {
+	runes := []rune(word)
+	var parts []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		lowerToUpper := unicode.IsLower(prev) && unicode.IsUpper(cur)
+		acronymEnd := unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		letterToDigit := unicode.IsLetter(prev) != unicode.IsLetter(cur)
+		if lowerToUpper || acronymEnd || letterToDigit {
+			parts = append(parts, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(parts, string(runes[start:]))
+}
+
+// RankedFile is a file with its relevance to a query
+type RankedFile struct {
+	Path  string
+	Score float64
+	Size  int64
+}
+
+type bm25Document struct {
+	path   string
+	size   int64
+	terms  map[string]int
+	length int
+}
+
+// BM25Index ranks files by relevance to a query
+type BM25Index struct {
+	documents []bm25Document
+	// Number of documents containing each term
+	frequencies map[string]int
+	totalLength int
+}
+
+func newBM25Index() *BM25Index {
+	return &BM25Index{frequencies: make(map[string]int)}
+}
+
+// Index a file by its relative path and content
+func (index *BM25Index) add(path, relPath string, size int64, content string) {
+	doc := bm25Document{path: path, size: size, terms: make(map[string]int)}
+	for _, term := range tokenize(filepath.ToSlash(relPath)) {
+		doc.terms[term] += pathTermWeight
+		doc.length += pathTermWeight
+	}
+	for _, term := range tokenize(content) {
+		doc.terms[term]++
+		doc.length++
+	}
+
+	for term := range doc.terms {
+		index.frequencies[term]++
+	}
+	index.totalLength += doc.length
+	index.documents = append(index.documents, doc)
+}
+
+// Score all files against the query, best first. Files without any query term are left out.
+func (index *BM25Index) rank(query string) []RankedFile {
+	if len(index.documents) == 0 {
+		return nil
+	}
+
+	terms := make(map[string]bool)
+	for _, term := range tokenize(query) {
+		terms[term] = true
+	}
+
+	count := float64(len(index.documents))
+	avgLength := float64(index.totalLength) / count
+	var ranked []RankedFile
+	for _, doc := range index.documents {
+		score := 0.0
+		for term := range terms {
+			frequency := float64(doc.terms[term])
+			if frequency == 0 {
+				continue
+			}
+			df := float64(index.frequencies[term])
+			idf := math.Log(1 + (count-df+0.5)/(df+0.5))
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(doc.length)/avgLength)
+			score += idf * frequency * (bm25K1 + 1) / (frequency + norm)
+		}
+		if score > 0 {
+			ranked = append(ranked, RankedFile{Path: doc.path, Score: score, Size: doc.size})
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	return ranked
+}
+
+// Take the best files up to top files and budget bytes; zero means no limit.
+// Files that do not fit the budget are passed over in favor of smaller ones.
+func selectRanked(ranked []RankedFile, top int, budget int64) []RankedFile {
+	var selected []RankedFile
+	var used int64
+	for _, file := range ranked {
+		if top > 0 && len(selected) >= top {
+			break
+		}
+		if budget > 0 && used+file.Size > budget {
+			continue
+		}
+		used += file.Size
+		selected = append(selected, file)
+	}
+	return selected
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// SecretRule describes a pattern of sensitive data.
+// If the pattern has capture groups only the first group is treated as the secret.
+// Files limits the rule to matching file globs.
+type SecretRule struct {
+	Name    string   `json:"name"`
+	Pattern string   `json:"pattern"`
+	Files   []string `json:"files,omitempty"`
+}
+
+// SecretsConfig controls secret detection
+type SecretsConfig struct {
+	Disabled         bool         `json:"disabled,omitempty"`
+	Rules            []SecretRule `json:"rules,omitempty"`
+	DisabledRules    []string     `json:"disabled_rules,omitempty"`
+	EntropyThreshold float64      `json:"entropy_threshold,omitempty"`
+	EntropyMinLength int          `json:"entropy_min_length,omitempty"`
+	AllowlistFile    string       `json:"allowlist_file,omitempty"`
+}
+
+// Return a copy of the settings with values of other applied; rules are added to the existing ones
+func (s SecretsConfig) override(other SecretsConfig) SecretsConfig {
+	if other.Disabled {
+		s.Disabled = true
+	}
+	s.Rules = append(append([]SecretRule{}, s.Rules...), other.Rules...)
+	s.DisabledRules = append(append([]string{}, s.DisabledRules...), other.DisabledRules...)
+	if other.EntropyThreshold > 0 {
+		s.EntropyThreshold = other.EntropyThreshold
+	}
+	if other.EntropyMinLength > 0 {
+		s.EntropyMinLength = other.EntropyMinLength
+	}
+	if other.AllowlistFile != "" {
+		s.AllowlistFile = other.AllowlistFile
+	}
+	return s
+}
+
+// Names of built-in detectors with special handling
+const (
+	privateKeyRule    = "private-key"
+	envAssignmentRule = "env-assignment"
+	highEntropyRule   = "high-entropy"
+)
+
+// Files where plain KEY=value assignments hold configuration
+var configFilePatterns = []string{
+	".env", "*.env", ".env.*", "*.ini", "*.cfg", "*.conf", "*.properties", "*.toml", "*.yaml", "*.yml",
+}
+
+// Built-in secret detectors
+var builtinSecretRules = []SecretRule{
+	{Name: privateKeyRule, Pattern: `-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`},
+	{Name: "aws-access-key-id", Pattern: `\b((?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16})\b`},
+	{Name: "aws-secret-access-key", Pattern: `(?i)aws.{0,20}?(?:secret|key).{0,20}?['"=:\s]+([A-Za-z0-9/+]{40})\b`},
+	{Name: "gcp-api-key", Pattern: `\b(AIza[0-9A-Za-z_\-]{35})`},
+	{Name: "gcp-service-account-key-id", Pattern: `"private_key_id"\s*:\s*"([0-9a-f]{40})"`},
+	{Name: "github-token", Pattern: `\b((?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,})\b`},
+	{Name: "github-fine-grained-token", Pattern: `\b(github_pat_[A-Za-z0-9_]{22,})\b`},
+	{Name: "slack-token", Pattern: `\b(xox[abprs]-[A-Za-z0-9-]{10,})\b`},
+	{
+		Name: envAssignmentRule,
+		Pattern: `(?i)^\s*(?:export\s+)?[A-Z0-9_.\-]*(?:SECRET|PASSWORD|PASSWD|PWD|TOKEN|API_?KEY|PRIVATE_?KEY|` +
+			`ACCESS_?KEY|CREDENTIALS?|AUTH)[A-Z0-9_.\-]*\s*[=:]\s*["']?([^"'\s#]+)`,
+		Files: configFilePatterns,
+	},
+}
+
+// Assigned values referring to variables like ${{ secrets.TOKEN }}, ${TOKEN} or $TOKEN, which are not secrets
+var variableReferenceRegexp = regexp.MustCompile(`^\$(?:\{|[A-Za-z_][A-Za-z0-9_]*$)`)
+
+// Candidates for the high-entropy check: quoted strings or assigned values
+var entropyCandidateRegexp = regexp.MustCompile(`["'=:]\s*["']?([A-Za-z0-9+/=_\-]+)`)
+
+// SecretFinding is a secret found in a file
+type SecretFinding struct {
+	Path   string
+	Line   int
+	Rule   string
+	Secret string
+}
+
+// Fingerprint identifies the secret independently of where it is found
+func (f SecretFinding) Fingerprint() string {
+	return secretFingerprint(f.Rule, f.Secret)
+}
+
+func secretFingerprint(rule, secret string) string {
+	const fingerprintLength = 16
+	sum := sha256.Sum256([]byte(rule + ":" + secret))
+	return hex.EncodeToString(sum[:])[:fingerprintLength]
+}
+
+// SecretsFoundError is returned when secrets are found in strict mode
+type SecretsFoundError struct {
+	Findings []SecretFinding
+}
+
+func (e *SecretsFoundError) Error() string {
+	return fmt.Sprintf("found %d secrets", len(e.Findings))
+}
+
+// Load fingerprints of accepted findings, one per line; text after # is a comment
+func loadSecretsAllowlist(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secrets allowlist: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	allowlist := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if fields := strings.Fields(line); len(fields) > 0 {
+			allowlist[fields[0]] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read secrets allowlist: %w", err)
+	}
+	return allowlist, nil
+}
+
+type compiledSecretRule struct {
+	SecretRule
+	regexp *regexp.Regexp
+}
+
+// SecretDetector finds secrets in file content
+type SecretDetector struct {
+	rules            []compiledSecretRule
+	entropy          bool
+	entropyThreshold float64
+	entropyMinLength int
+	allowlist        map[string]bool
+}
+
+// Compile built-in and user rules. Findings with fingerprints in allowlist are ignored.
+func newSecretDetector(settings SecretsConfig, allowlist map[string]bool) (*SecretDetector, error) {
+	disabled := make(map[string]bool)
+	for _, name := range settings.DisabledRules {
+		disabled[name] = true
+	}
+
+	detector := &SecretDetector{
+		entropy:          !disabled[highEntropyRule],
+		entropyThreshold: settings.EntropyThreshold,
+		entropyMinLength: settings.EntropyMinLength,
+		allowlist:        allowlist,
+	}
+	for _, rule := range append(append([]SecretRule{}, builtinSecretRules...), settings.Rules...) {
+		if disabled[rule.Name] {
+			continue
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret rule %q: %w", rule.Name, err)
+		}
+		detector.rules = append(detector.rules, compiledSecretRule{SecretRule: rule, regexp: re})
+	}
+	return detector, nil
+}
+
+// Find secrets in lines and replace them with placeholders.
+// Returns the redacted lines and what has been found.
+func (d *SecretDetector) redact(relPath string, lines []SourceLine) (result []SourceLine, findings []SecretFinding) {
+	var rules []compiledSecretRule
+	for _, rule := range d.rules {
+		if rule.appliesTo(relPath) {
+			rules = append(rules, rule)
+		}
+	}
+
+	// Lines of a multi-line private key collected until its END marker
+	var keyLines []SourceLine
+	for i, line := range lines {
+		if keyLines != nil {
+			keyLines = append(keyLines, line)
+			if !strings.Contains(line.Text, "-----END ") && i < len(lines)-1 {
+				continue
+			}
+			result = append(result, d.redactPrivateKey(relPath, keyLines, &findings)...)
+			keyLines = nil
+			continue
+		}
+		if d.startsPrivateKey(line.Text, rules) {
+			keyLines = []SourceLine{line}
+			if i == len(lines)-1 {
+				result = append(result, d.redactPrivateKey(relPath, keyLines, &findings)...)
+			}
+			continue
+		}
+
+		text := line.Text
+		for _, rule := range rules {
+			text = rule.replace(text, func(secret string) bool {
+				return d.report(&findings, SecretFinding{Path: relPath, Line: line.Number, Rule: rule.Name, Secret: secret})
+			})
+		}
+		if d.entropy {
+			text = d.replaceHighEntropy(text, func(secret string) bool {
+				return d.report(&findings, SecretFinding{Path: relPath, Line: line.Number, Rule: highEntropyRule, Secret: secret})
+			})
+		}
+		result = append(result, SourceLine{Number: line.Number, Text: text})
+	}
+	return result, findings
+}
+
+// Check if the line begins a private key that continues on the next lines
+func (d *SecretDetector) startsPrivateKey(text string, rules []compiledSecretRule) bool {
+	for _, rule := range rules {
+		if rule.Name != privateKeyRule {
+			continue
+		}
+		loc := rule.regexp.FindStringIndex(text)
+		return loc != nil && !strings.Contains(text[loc[1]:], "-----END ")
+	}
+	return false
+}
+
+// Replace the body of a multi-line private key with a single placeholder, keeping the BEGIN and END lines
+func (d *SecretDetector) redactPrivateKey(relPath string, keyLines []SourceLine, findings *[]SecretFinding) []SourceLine {
+	last := len(keyLines) - 1
+	hasEnd := last > 0 && strings.Contains(keyLines[last].Text, "-----END ")
+	body := keyLines[1:]
+	if hasEnd {
+		body = keyLines[1:last]
+	}
+
+	var secret strings.Builder
+	for _, line := range body {
+		secret.WriteString(strings.TrimSpace(line.Text))
+	}
+	finding := SecretFinding{Path: relPath, Line: keyLines[0].Number, Rule: privateKeyRule, Secret: secret.String()}
+	if !d.report(findings, finding) {
+		return keyLines
+	}
+
+	result := []SourceLine{{Number: keyLines[0].Number, Text: keyLines[0].Text + "[REDACTED:" + privateKeyRule + "]"}}
+	if hasEnd {
+		result = append(result, keyLines[last])
+	}
+	return result
+}
+
+// Add the finding unless it is allowlisted, tells whether the secret should be replaced
+func (d *SecretDetector) report(findings *[]SecretFinding, finding SecretFinding) bool {
+	if d.allowlist[finding.Fingerprint()] {
+		return false
+	}
+	*findings = append(*findings, finding)
+	return true
+}
+
+func (rule *compiledSecretRule) appliesTo(relPath string) bool {
+	if len(rule.Files) == 0 {
+		return true
+	}
+	for _, pattern := range rule.Files {
+		if matchPathGlob(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Replace matches of the rule in text, calling found for every secret to decide whether to replace it
+func (rule *compiledSecretRule) replace(text string, found func(secret string) bool) string {
+	placeholder := "[REDACTED:" + rule.Name + "]"
+	if rule.Name == privateKeyRule {
+		return rule.replacePrivateKey(text, placeholder, found)
+	}
+
+	matches := rule.regexp.FindAllStringSubmatchIndex(text, -1)
+	for i := len(matches) - 1; i >= 0; i-- {
+		start, end := matches[i][0], matches[i][1]
+		if len(matches[i]) > 2 && matches[i][2] >= 0 {
+			start, end = matches[i][2], matches[i][3]
+		}
+		if strings.HasPrefix(text[start:end], "[REDACTED:") {
+			continue
+		}
+		if rule.Name == envAssignmentRule && variableReferenceRegexp.MatchString(text[start:end]) {
+			continue
+		}
+		if !found(text[start:end]) {
+			continue
+		}
+		text = text[:start] + placeholder + text[end:]
+	}
+	return text
+}
+
+// Keep the BEGIN and END markers of a private key and replace what is between them
+func (rule *compiledSecretRule) replacePrivateKey(text, placeholder string, found func(secret string) bool) string {
+	loc := rule.regexp.FindStringIndex(text)
+	if loc == nil {
+		return text
+	}
+	rest := text[loc[1]:]
+	if end := strings.Index(rest, "-----END "); end >= 0 {
+		if !found(rest[:end]) {
+			return text
+		}
+		return text[:loc[1]] + placeholder + rest[end:]
+	}
+	if !found(rest) {
+		return text
+	}
+	return text[:loc[1]] + placeholder
+}
+
+// Replace random-looking values, calling found for every secret to decide whether to replace it
+func (d *SecretDetector) replaceHighEntropy(text string, found func(secret string) bool) string {
+	matches := entropyCandidateRegexp.FindAllStringSubmatchIndex(text, -1)
+	for i := len(matches) - 1; i >= 0; i-- {
+		start, end := matches[i][2], matches[i][3]
+		candidate := text[start:end]
+		if len(candidate) < d.entropyMinLength || !isLikelySecret(candidate, d.entropyThreshold) || !found(candidate) {
+			continue
+		}
+		text = text[:start] + "[REDACTED:" + highEntropyRule + "]" + text[end:]
+	}
+	return text
+}
+
+// Secrets mix letters and digits and look random
+func isLikelySecret(value string, threshold float64) bool {
+	hasLetter, hasDigit := false, false
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+			hasLetter = true
+		}
+	}
+	return hasLetter && hasDigit && shannonEntropy(value) >= threshold
+}
+
+// Shannon entropy of a string in bits per character
+func shannonEntropy(value string) float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range value {
+		counts[r]++
+		total++
+	}
+
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
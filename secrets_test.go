@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestEnvAssignmentRule(t *testing.T) {
+	detector, err := newSecretDetector(SecretsConfig{DisabledRules: []string{highEntropyRule}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		line   string
+		secret string
+	}{
+		{".env", "API_KEY=abc123def456", "abc123def456"},
+		{".env", "export DB_PASSWORD='hunter2hunter2'", "hunter2hunter2"},
+		{"config.yaml", "password: $3cr3tValue99", "$3cr3tValue99"},
+		{"config.yaml", "auth_token: \"s3cr3t$tail\" # comment", "s3cr3t$tail"},
+		{"app.ini", "secret = pa$$word", "pa$$word"},
+		{"config.yaml", "token: ${{ secrets.TOKEN }}", ""},
+		{"config.yaml", "password: ${DB_PASSWORD}", ""},
+		{".env", "API_KEY=$API_KEY", ""},
+		{"settings.toml", "password = \"$PASSWORD\"", ""},
+		{"main.go", "password := \"hunter2hunter2\"", ""},
+		{".env", "USERNAME=admin", ""},
+	}
+	for _, test := range tests {
+		_, findings := detector.redact(test.path, []SourceLine{{Number: 1, Text: test.line}})
+		var secret string
+		for _, finding := range findings {
+			if finding.Rule == envAssignmentRule {
+				secret = finding.Secret
+			}
+		}
+		if secret != test.secret {
+			t.Errorf("%s: %q: found %q, want %q", test.path, test.line, secret, test.secret)
+		}
+	}
+}
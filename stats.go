@@ -0,0 +1,171 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Number of files listed as the largest
+const largestFilesCount = 10
+
+// Group name of files without a language
+const otherLanguage = "other"
+
+// LineCounts holds cloc-style line counts. Lines with code and a comment count as code.
+type LineCounts struct {
+	Lines   int `json:"lines"`
+	Blank   int `json:"blank"`
+	Comment int `json:"comment"`
+	Code    int `json:"code"`
+}
+
+func (c *LineCounts) add(other LineCounts) {
+	c.Lines += other.Lines
+	c.Blank += other.Blank
+	c.Comment += other.Comment
+	c.Code += other.Code
+}
+
+// GroupStats sums up the files of a language or directory.
+// Bytes are the sizes of the files, tokens are estimated from the content written to the archive.
+type GroupStats struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+	LineCounts
+	Tokens int64 `json:"tokens"`
+}
+
+func (g *GroupStats) add(file FileStats) {
+	g.Files++
+	g.Bytes += file.Bytes
+	g.LineCounts.add(file.LineCounts)
+	g.Tokens += file.Tokens
+}
+
+// FileStats holds the statistics of a single archived file
+type FileStats struct {
+	Path     string `json:"path"`
+	Language string `json:"language"`
+	Bytes    int64  `json:"bytes"`
+	LineCounts
+	Tokens int64 `json:"tokens"`
+}
+
+// Count blank, comment and code lines, recognizing comments of the language
+func countLines(lines []SourceLine, language string) LineCounts {
+	counts := LineCounts{Lines: len(lines)}
+	if len(lines) == 0 {
+		return counts
+	}
+
+	text := joinLines(lines)
+	var comments []commentSpan
+	switch {
+	case language == "go":
+		comments, _ = scanGoComments(text)
+	case canStrip(language):
+		comments, _ = scanComments(text, commentSyntaxes[language])
+	}
+	inComment := make([]bool, len(text))
+	for _, comment := range comments {
+		for i := comment.start; i < comment.end; i++ {
+			inComment[i] = true
+		}
+	}
+
+	offset := 0
+	for _, line := range lines {
+		hasCode, hasComment := false, false
+		for i := 0; i < len(line.Text); i++ {
+			switch {
+			case line.Text[i] == ' ' || line.Text[i] == '\t' || line.Text[i] == '\r':
+			case inComment[offset+i]:
+				hasComment = true
+			default:
+				hasCode = true
+			}
+		}
+		offset += len(line.Text) + 1
+
+		switch {
+		case hasCode:
+			counts.Code++
+		case hasComment:
+			counts.Comment++
+		default:
+			counts.Blank++
+		}
+	}
+	return counts
+}
+
+// Top-level directory of a file, "." for files in the project root
+func topDirectory(relPath string) string {
+	dir, _, found := strings.Cut(filepath.ToSlash(relPath), "/")
+	if !found {
+		return "."
+	}
+	return dir
+}
+
+// StatsReport is the statistics of a run as written by -stats-json
+type StatsReport struct {
+	Files        int   `json:"files"`
+	SkippedDirs  int   `json:"skipped_dirs"`
+	Bytes        int64 `json:"bytes"`
+	ContentBytes int64 `json:"content_bytes"`
+	LineCounts
+	Tokens      int64                  `json:"tokens"`
+	DurationMs  int64                  `json:"duration_ms"`
+	Languages   map[string]*GroupStats `json:"languages"`
+	Directories map[string]*GroupStats `json:"directories"`
+	Largest     []FileStats            `json:"largest_files"`
+}
+
+// Sum up the statistics of the files by language and top-level directory
+func newStatsReport(files []FileStats) *StatsReport {
+	report := &StatsReport{
+		Languages:   make(map[string]*GroupStats),
+		Directories: make(map[string]*GroupStats),
+	}
+	for _, file := range files {
+		report.Files++
+		report.Bytes += file.Bytes
+		report.LineCounts.add(file.LineCounts)
+		report.Tokens += file.Tokens
+
+		language := file.Language
+		if language == "" {
+			language = otherLanguage
+		}
+		if report.Languages[language] == nil {
+			report.Languages[language] = &GroupStats{}
+		}
+		report.Languages[language].add(file)
+
+		dir := topDirectory(file.Path)
+		if report.Directories[dir] == nil {
+			report.Directories[dir] = &GroupStats{}
+		}
+		report.Directories[dir].add(file)
+	}
+
+	report.Largest = append([]FileStats{}, files...)
+	sort.SliceStable(report.Largest, func(i, j int) bool {
+		return report.Largest[i].Bytes > report.Largest[j].Bytes
+	})
+	if len(report.Largest) > largestFilesCount {
+		report.Largest = report.Largest[:largestFilesCount]
+	}
+	return report
+}
+
+// Group names ordered by code lines, then by name
+func groupsByCode(groups map[string]*GroupStats) []string {
+	names := sortedKeys(groups)
+	sort.SliceStable(names, func(i, j int) bool {
+		return groups[names[i]].Code > groups[names[j]].Code
+	})
+	return names
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestCountLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		text     string
+		want     LineCounts
+	}{
+		{"empty", "go", "", LineCounts{}},
+		{
+			"go",
+			"go",
+			"// Package a does a\npackage a\n\n/* block\n   comment */\nvar s = \"// not a comment\" // trailing\n\t\r\n",
+			LineCounts{Lines: 7, Blank: 2, Comment: 3, Code: 2},
+		},
+		{
+			"go raw string",
+			"go",
+			"var s = `\n/* inside */\n`\n",
+			LineCounts{Lines: 3, Code: 3},
+		},
+		{
+			"python",
+			"python",
+			"# comment\nimport os\n\ns = '# not a comment'\n",
+			LineCounts{Lines: 4, Blank: 1, Comment: 1, Code: 2},
+		},
+		{
+			"crlf",
+			"python",
+			"# comment\r\nx = 1\r\n\r\n",
+			LineCounts{Lines: 3, Blank: 1, Comment: 1, Code: 1},
+		},
+		{
+			"unknown language",
+			"",
+			"// looks like a comment\n\ntext\n",
+			LineCounts{Lines: 3, Blank: 1, Code: 2},
+		},
+	}
+	for _, test := range tests {
+		got := countLines(splitSourceLines(test.text), test.language)
+		if got != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
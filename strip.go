@@ -0,0 +1,497 @@
+package main
+
+import (
+	"go/scanner"
+	"go/token"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// StripConfig controls removal of comments and redundant whitespace
+type StripConfig struct {
+	Enabled       bool `json:"enabled,omitempty"`
+	KeepDocs      bool `json:"keep_docs,omitempty"`
+	MaxBlankLines int  `json:"max_blank_lines,omitempty"`
+}
+
+// Return a copy of the settings with non-zero values of other applied
+func (s StripConfig) override(other StripConfig) StripConfig {
+	if other.Enabled {
+		s.Enabled = true
+	}
+	if other.KeepDocs {
+		s.KeepDocs = true
+	}
+	if other.MaxBlankLines > 0 {
+		s.MaxBlankLines = other.MaxBlankLines
+	}
+	return s
+}
+
+// Header line of an archive with stripped content
+const strippedHeader = "Stripped: comments and redundant whitespace removed"
+
+// commentSyntax describes comments and string literals of a language
+type commentSyntax struct {
+	lineComments  []string
+	blockComments [][2]string
+	// Delimiters of strings ending at the line break and of strings spanning lines
+	strings          []string
+	multilineStrings []string
+	// Line comments start only at the beginning of a word, like # in shell scripts
+	wordStart bool
+	// Line comments start only at the beginning of a line, like ; in ini files
+	lineStart bool
+	// ' starts a character literal or a lifetime, not a string
+	charLiterals bool
+	docPrefixes  []string
+}
+
+var (
+	cComments = commentSyntax{
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		strings:       []string{`"`, `'`},
+		docPrefixes:   []string{"///", "//!", "/**", "/*!"},
+	}
+	hashComments = commentSyntax{
+		lineComments:     []string{"#"},
+		multilineStrings: []string{`"`, `'`},
+	}
+)
+
+// Syntax of languages by the names used in the Languages map; Go is handled by go/scanner
+var commentSyntaxes = map[string]commentSyntax{
+	"c":          cComments,
+	"cpp":        cComments,
+	"objectivec": cComments,
+	"csharp":     cComments,
+	"java":       cComments,
+	"kotlin":     withMultilineStrings(cComments, `"""`),
+	"scala":      withMultilineStrings(cComments, `"""`),
+	"swift":      withMultilineStrings(cComments, `"""`),
+	"javascript": withMultilineStrings(cComments, "`"),
+	"jsx":        withMultilineStrings(cComments, "`"),
+	"typescript": withMultilineStrings(cComments, "`"),
+	"tsx":        withMultilineStrings(cComments, "`"),
+	"rust": {
+		lineComments:     []string{"//"},
+		blockComments:    [][2]string{{"/*", "*/"}},
+		multilineStrings: []string{`"`},
+		charLiterals:     true,
+		docPrefixes:      []string{"///", "//!", "/**", "/*!"},
+	},
+	"php": {
+		lineComments:     []string{"//", "#"},
+		blockComments:    [][2]string{{"/*", "*/"}},
+		multilineStrings: []string{`"`, `'`},
+		docPrefixes:      []string{"/**"},
+	},
+	"css":  {blockComments: [][2]string{{"/*", "*/"}}, strings: []string{`"`, `'`}},
+	"less": {lineComments: []string{"//"}, blockComments: [][2]string{{"/*", "*/"}}, strings: []string{`"`, `'`}},
+	"scss": {lineComments: []string{"//"}, blockComments: [][2]string{{"/*", "*/"}}, strings: []string{`"`, `'`}},
+	"sass": {lineComments: []string{"//"}, blockComments: [][2]string{{"/*", "*/"}}, strings: []string{`"`, `'`}},
+	"python": {
+		lineComments:     []string{"#"},
+		strings:          []string{`"`, `'`},
+		multilineStrings: []string{`"""`, `'''`},
+	},
+	"ruby": {
+		lineComments:     []string{"#"},
+		blockComments:    [][2]string{{"=begin", "=end"}},
+		multilineStrings: []string{`"`, `'`},
+	},
+	"r":          hashComments,
+	"bash":       {lineComments: []string{"#"}, multilineStrings: []string{`"`, `'`}, wordStart: true},
+	"makefile":   {lineComments: []string{"#"}, wordStart: true},
+	"dockerfile": {lineComments: []string{"#"}, lineStart: true},
+	"powershell": {
+		lineComments:     []string{"#"},
+		blockComments:    [][2]string{{"<#", "#>"}},
+		multilineStrings: []string{`"`, `'`},
+		wordStart:        true,
+	},
+	"yaml": {lineComments: []string{"#"}, strings: []string{`"`, `'`}, wordStart: true},
+	"toml": {
+		lineComments:     []string{"#"},
+		strings:          []string{`"`, `'`},
+		multilineStrings: []string{`"""`, `'''`},
+	},
+	"ini": {lineComments: []string{";", "#"}, lineStart: true},
+	"sql": {
+		lineComments:     []string{"--"},
+		blockComments:    [][2]string{{"/*", "*/"}},
+		multilineStrings: []string{`'`, `"`},
+	},
+	"haskell": {
+		lineComments:  []string{"--"},
+		blockComments: [][2]string{{"{-", "-}"}},
+		strings:       []string{`"`},
+		docPrefixes:   []string{"-- |", "{- |"},
+	},
+	"ocaml":   {blockComments: [][2]string{{"(*", "*)"}}, strings: []string{`"`}, docPrefixes: []string{"(**"}},
+	"clojure": {lineComments: []string{";"}, multilineStrings: []string{`"`}},
+	"html":    {blockComments: [][2]string{{"<!--", "-->"}}},
+	"xml":     {blockComments: [][2]string{{"<!--", "-->"}}},
+	"json":    {strings: []string{`"`}},
+}
+
+func withMultilineStrings(syntax commentSyntax, delimiters ...string) commentSyntax {
+	syntax.multilineStrings = append(append([]string{}, syntax.multilineStrings...), delimiters...)
+	return syntax
+}
+
+// License headers are recognized by these words in the leading comments of a file
+var licenseRegexp = regexp.MustCompile(`(?i)copyright|license|spdx-license-identifier`)
+
+// textSpan is a range of bytes in the file content
+type textSpan struct {
+	start, end int
+}
+
+// commentSpan is a comment in the file content
+type commentSpan struct {
+	textSpan
+	// Documentation comments are kept on request
+	doc bool
+	// Directives affect the meaning of the code and are always kept
+	directive bool
+}
+
+// Check if comments of the language can be stripped
+func canStrip(language string) bool {
+	_, exists := commentSyntaxes[language]
+	return exists || language == "go"
+}
+
+// Remove comments, trailing whitespace and runs of blank lines.
+// String literals are never changed and kept lines retain their numbers.
+func stripLines(lines []SourceLine, language string, settings StripConfig) []SourceLine {
+	if !canStrip(language) || len(lines) == 0 {
+		return lines
+	}
+
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.Text
+	}
+	text := strings.Join(texts, "\n")
+
+	var comments []commentSpan
+	var literals []textSpan
+	if language == "go" {
+		comments, literals = scanGoComments(text)
+	} else {
+		comments, literals = scanComments(text, commentSyntaxes[language])
+	}
+	markLicenseHeader(text, comments)
+
+	removed := make([]bool, len(text))
+	for _, comment := range comments {
+		if comment.directive || (comment.doc && settings.KeepDocs) {
+			continue
+		}
+		for i := comment.start; i < comment.end; i++ {
+			removed[i] = true
+		}
+	}
+
+	var result []SourceLine
+	blanks := 0
+	offset := 0
+	for _, line := range lines {
+		// The carriage return of a CRLF line break is kept after the stripped line
+		body := strings.TrimSuffix(line.Text, "\r")
+		lineEnd := line.Text[len(body):]
+		start, end := offset, offset+len(body)
+		offset += len(line.Text) + 1
+
+		// Content of multi-line strings is kept as is
+		continuesString := insideSpan(literals, start)
+		endsInString := insideSpan(literals, end)
+
+		var kept strings.Builder
+		for i := start; i < end; i++ {
+			if !removed[i] {
+				kept.WriteByte(text[i])
+			}
+		}
+		stripped := kept.String()
+		if !endsInString {
+			stripped = strings.TrimRight(stripped, " \t")
+		}
+
+		if stripped == "" && !continuesString {
+			// Lines holding only comments disappear, blank lines are limited
+			if strings.TrimSpace(line.Text) != "" || len(result) == 0 || blanks >= settings.MaxBlankLines {
+				continue
+			}
+			blanks++
+		} else {
+			blanks = 0
+		}
+		result = append(result, SourceLine{Number: line.Number, Text: stripped + lineEnd})
+	}
+
+	for len(result) > 0 && strings.TrimSuffix(result[len(result)-1].Text, "\r") == "" && blanks > 0 {
+		result = result[:len(result)-1]
+		blanks--
+	}
+	return result
+}
+
+// Check if the offset is inside a span, but not at its start
+func insideSpan(spans []textSpan, offset int) bool {
+	for _, span := range spans {
+		if offset > span.start && offset < span.end {
+			return true
+		}
+	}
+	return false
+}
+
+// Leading comments mentioning a license are treated as documentation
+func markLicenseHeader(text string, comments []commentSpan) {
+	offset := 0
+	var header []int
+	for i, comment := range comments {
+		if strings.TrimSpace(text[offset:comment.start]) != "" {
+			break
+		}
+		header = append(header, i)
+		offset = comment.end
+	}
+
+	isLicense := false
+	for _, i := range header {
+		if licenseRegexp.MatchString(text[comments[i].start:comments[i].end]) {
+			isLicense = true
+			break
+		}
+	}
+	if isLicense {
+		for _, i := range header {
+			comments[i].doc = true
+		}
+	}
+}
+
+// Find comments and string literals of a language described by syntax
+func scanComments(text string, syntax commentSyntax) (comments []commentSpan, literals []textSpan) {
+	for i := 0; i < len(text); {
+		if syntax.charLiterals && text[i] == '\'' {
+			i += charLiteralLength(text[i:])
+			continue
+		}
+
+		if delimiter, multiline := syntax.stringAt(text, i); delimiter != "" {
+			end := stringEnd(text, i+len(delimiter), delimiter, multiline)
+			literals = append(literals, textSpan{i, end})
+			i = end
+			continue
+		}
+
+		if open, closing := syntax.blockCommentAt(text, i); open != "" {
+			end := len(text)
+			if index := strings.Index(text[i+len(open):], closing); index >= 0 {
+				end = i + len(open) + index + len(closing)
+			}
+			comments = append(comments, syntax.comment(text, i, end))
+			i = end
+			continue
+		}
+
+		if syntax.lineCommentAt(text, i) {
+			end := len(text)
+			if index := strings.IndexByte(text[i:], '\n'); index >= 0 {
+				end = i + index
+			}
+			comment := syntax.comment(text, i, end)
+			// Shebang line
+			if i == 0 && strings.HasPrefix(text, "#!") {
+				comment.directive = true
+			}
+			comments = append(comments, comment)
+			i = end
+			continue
+		}
+		i++
+	}
+	return comments, literals
+}
+
+func (syntax *commentSyntax) comment(text string, start, end int) commentSpan {
+	comment := commentSpan{textSpan: textSpan{start, end}}
+	for _, prefix := range syntax.docPrefixes {
+		if strings.HasPrefix(text[start:end], prefix) {
+			comment.doc = true
+		}
+	}
+	return comment
+}
+
+// Opening delimiter of a string at position i; longer delimiters are checked first
+func (syntax *commentSyntax) stringAt(text string, i int) (delimiter string, multiline bool) {
+	for _, delimiter := range syntax.multilineStrings {
+		if len(delimiter) > 1 && strings.HasPrefix(text[i:], delimiter) {
+			return delimiter, true
+		}
+	}
+	for _, delimiter := range syntax.strings {
+		if strings.HasPrefix(text[i:], delimiter) {
+			return delimiter, false
+		}
+	}
+	for _, delimiter := range syntax.multilineStrings {
+		if strings.HasPrefix(text[i:], delimiter) {
+			return delimiter, true
+		}
+	}
+	return "", false
+}
+
+func (syntax *commentSyntax) blockCommentAt(text string, i int) (open, closing string) {
+	for _, block := range syntax.blockComments {
+		if strings.HasPrefix(text[i:], block[0]) {
+			return block[0], block[1]
+		}
+	}
+	return "", ""
+}
+
+func (syntax *commentSyntax) lineCommentAt(text string, i int) bool {
+	if syntax.wordStart && i > 0 && !strings.ContainsRune(" \t\n", rune(text[i-1])) {
+		return false
+	}
+	if syntax.lineStart {
+		lineBegin := strings.LastIndexByte(text[:i], '\n') + 1
+		if strings.TrimSpace(text[lineBegin:i]) != "" {
+			return false
+		}
+	}
+	for _, prefix := range syntax.lineComments {
+		if strings.HasPrefix(text[i:], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find the end of a string literal starting at i, escapes are skipped.
+// Unterminated single-line strings end at the line break.
+func stringEnd(text string, i int, delimiter string, multiline bool) int {
+	for i < len(text) {
+		switch {
+		case text[i] == '\\':
+			i += 2
+			continue
+		case strings.HasPrefix(text[i:], delimiter):
+			return i + len(delimiter)
+		case text[i] == '\n' && !multiline:
+			return i
+		}
+		i++
+	}
+	return len(text)
+}
+
+// Length of a character literal like 'a' or '\n' at the start of text, 1 for a lifetime like 'a
+func charLiteralLength(text string) int {
+	if len(text) > 1 && text[1] == '\\' {
+		if end := strings.IndexByte(text[2:], '\''); end >= 0 {
+			return end + 3
+		}
+		return 1
+	}
+	_, size := utf8.DecodeRuneInString(text[1:])
+	if 1+size < len(text) && text[1+size] == '\'' {
+		return size + 2
+	}
+	return 1
+}
+
+// Go comments that are compiler directives
+var goDirectivePrefixes = []string{"//go:", "//line ", "// +build", "//export ", "//extern ", "//sys "}
+
+// goToken is a token of a Go source file
+type goToken struct {
+	tok           token.Token
+	lit           string
+	offset        int
+	line, endLine int
+}
+
+// Find comments and string literals of Go code. Doc comments are comment groups directly
+// above top-level declarations, specs of grouped declarations and struct or interface members.
+func scanGoComments(text string) (comments []commentSpan, literals []textSpan) {
+	fileSet := token.NewFileSet()
+	file := fileSet.AddFile("", fileSet.Base(), len(text))
+	var s scanner.Scanner
+	s.Init(file, []byte(text), nil, scanner.ScanComments)
+
+	var tokens []goToken
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		// Skip semicolons inserted at line breaks
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+		if lit == "" {
+			lit = tok.String()
+		}
+		offset := file.Offset(pos)
+		tokens = append(tokens, goToken{
+			tok:     tok,
+			lit:     lit,
+			offset:  offset,
+			line:    file.Line(pos),
+			endLine: file.Line(pos) + strings.Count(lit, "\n"),
+		})
+	}
+
+	// Braces of struct and interface types are true
+	var braces []bool
+	previousLine := 0
+	for i, t := range tokens {
+		switch t.tok {
+		case token.LBRACE:
+			braces = append(braces, i > 0 && (tokens[i-1].tok == token.STRUCT || tokens[i-1].tok == token.INTERFACE))
+		case token.RBRACE:
+			if len(braces) > 0 {
+				braces = braces[:len(braces)-1]
+			}
+		case token.STRING, token.CHAR:
+			literals = append(literals, textSpan{t.offset, t.offset + len(t.lit)})
+		case token.COMMENT:
+			comment := commentSpan{textSpan: textSpan{t.offset, t.offset + len(t.lit)}}
+			for _, prefix := range goDirectivePrefixes {
+				if strings.HasPrefix(t.lit, prefix) {
+					comment.directive = true
+				}
+			}
+
+			// Find the code following the comment group
+			next, groupEnd := i+1, t.endLine
+			for next < len(tokens) && tokens[next].tok == token.COMMENT && tokens[next].line <= groupEnd+1 {
+				groupEnd = tokens[next].endLine
+				next++
+			}
+			if next < len(tokens) && tokens[next].line == groupEnd+1 {
+				declaration := len(braces) == 0 || braces[len(braces)-1]
+				comment.doc = previousLine != t.line && declaration
+				// Preamble of cgo code
+				if tokens[next].tok == token.IMPORT && next+1 < len(tokens) && tokens[next+1].lit == `"C"` {
+					comment.directive = true
+				}
+			}
+			comments = append(comments, comment)
+			continue
+		}
+		previousLine = t.endLine
+	}
+	return comments, literals
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// lineRange is a range of line numbers, both ends included
+type lineRange struct {
+	start, end int
+}
+
+// Format ranges like "12-40, 55"
+func formatLineRanges(ranges []lineRange) string {
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		if r.start == r.end {
+			parts[i] = fmt.Sprint(r.start)
+		} else {
+			parts[i] = fmt.Sprintf("%d-%d", r.start, r.end)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Sort ranges and join those that overlap or are separated by blank lines only
+func mergeLineRanges(ranges []lineRange, lines []SourceLine) []lineRange {
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].start < ranges[j].start
+	})
+
+	blankGap := func(from, to int) bool {
+		for number := from; number <= to; number++ {
+			if number >= 1 && number <= len(lines) && strings.TrimSpace(lines[number-1].Text) != "" {
+				return false
+			}
+		}
+		return true
+	}
+
+	var merged []lineRange
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && blankGap(merged[n-1].end+1, r.start-1) {
+			merged[n-1].end = max(merged[n-1].end, r.end)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// Keep the lines within the ranges, marking the lines left out between them
+func selectLineRanges(lines []SourceLine, ranges []lineRange) []SourceLine {
+	var result []SourceLine
+	for i, r := range ranges {
+		if i > 0 {
+			result = append(result, omissionMarker(r.start-ranges[i-1].end-1, "not selected"))
+		}
+		for _, line := range lines {
+			if line.Number >= r.start && line.Number <= r.end {
+				result = append(result, line)
+			}
+		}
+	}
+	return result
+}
+
+// Split a symbol like "pkg.Func", "Type.Method" or "Class::method" into its names
+func parseSymbol(symbol string) []string {
+	return strings.Split(strings.ReplaceAll(symbol, "::", "."), ".")
+}
+
+// Check if a declaration matches the symbol. A bare name matches declarations of any container,
+// a qualifier may be the container, like a receiver type or a class, or the package or module.
+func symbolMatches(symbol []string, name, container, module string) bool {
+	switch len(symbol) {
+	case 1:
+		return name == symbol[0]
+	case 2:
+		return name == symbol[1] && (container == symbol[0] || container == "" && module == symbol[0])
+	case 3:
+		return name == symbol[2] && container == symbol[1] && module == symbol[0]
+	}
+	return false
+}
+
+// Find the declarations of symbols in the Go files of one package, with the definitions
+// of package types they refer to directly. Matched symbols are marked in found.
+func goSymbolRanges(paths []string, symbols [][]string, found []bool) map[string][]lineRange {
+	type goDecl struct {
+		path string
+		decl ast.Decl
+	}
+
+	fileSet := token.NewFileSet()
+	var decls []goDecl
+	types := make(map[string][]goDecl)
+	lines := make(map[string][]SourceLine)
+	packageName := ""
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		file, err := parser.ParseFile(fileSet, path, content, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		lines[path] = splitSourceLines(string(content))
+		packageName = file.Name.Name
+		for _, decl := range file.Decls {
+			decls = append(decls, goDecl{path, decl})
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+				for _, spec := range gen.Specs {
+					name := spec.(*ast.TypeSpec).Name.Name
+					types[name] = append(types[name], goDecl{path, decl})
+				}
+			}
+		}
+	}
+	if len(decls) == 0 {
+		return nil
+	}
+	dirName := filepath.Base(filepath.Dir(paths[0]))
+
+	selected := make(map[ast.Decl]string)
+	var matched []goDecl
+	for _, d := range decls {
+		for _, name := range goDeclNames(d.decl) {
+			for i, symbol := range symbols {
+				if symbolMatches(symbol, name[1], name[0], packageName) || symbolMatches(symbol, name[1], name[0], dirName) {
+					found[i] = true
+					if _, exists := selected[d.decl]; !exists {
+						selected[d.decl] = d.path
+						matched = append(matched, d)
+					}
+				}
+			}
+		}
+	}
+
+	// Types used by the types are not followed, that would pull in most of the package
+	for _, d := range matched {
+		for _, name := range referencedNames(d.decl) {
+			for _, typeDecl := range types[name] {
+				selected[typeDecl.decl] = typeDecl.path
+			}
+		}
+	}
+
+	ranges := make(map[string][]lineRange)
+	for decl, path := range selected {
+		start := decl.Pos()
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Doc != nil {
+				start = decl.Doc.Pos()
+			}
+		case *ast.GenDecl:
+			if decl.Doc != nil {
+				start = decl.Doc.Pos()
+			}
+		}
+		ranges[path] = append(ranges[path], lineRange{fileSet.Position(start).Line, fileSet.Position(decl.End()).Line})
+	}
+	for path := range ranges {
+		ranges[path] = mergeLineRanges(ranges[path], lines[path])
+	}
+	return ranges
+}
+
+// Names declared by a Go declaration as pairs of receiver type and name
+func goDeclNames(decl ast.Decl) [][2]string {
+	var names [][2]string
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		receiver := ""
+		if decl.Recv != nil && len(decl.Recv.List) > 0 {
+			receiver = receiverTypeName(decl.Recv.List[0].Type)
+		}
+		names = append(names, [2]string{receiver, decl.Name.Name})
+	case *ast.GenDecl:
+		for _, spec := range decl.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, [2]string{"", spec.Name.Name})
+			case *ast.ValueSpec:
+				for _, name := range spec.Names {
+					names = append(names, [2]string{"", name.Name})
+				}
+			}
+		}
+	}
+	return names
+}
+
+// Name of the receiver type of a method, without pointer and type parameters
+func receiverTypeName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverTypeName(expr.X)
+	case *ast.IndexExpr:
+		return receiverTypeName(expr.X)
+	case *ast.IndexListExpr:
+		return receiverTypeName(expr.X)
+	case *ast.ParenExpr:
+		return receiverTypeName(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
+
+// Unqualified identifiers used in a declaration; selectors of other packages and fields are left out
+func referencedNames(node ast.Node) []string {
+	var names []string
+	seen := make(map[string]bool)
+	var inspect func(node ast.Node) bool
+	inspect = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.SelectorExpr:
+			ast.Inspect(node.X, inspect)
+			return false
+		case *ast.Ident:
+			if !seen[node.Name] {
+				seen[node.Name] = true
+				names = append(names, node.Name)
+			}
+		}
+		return true
+	}
+	ast.Inspect(node, inspect)
+	return names
+}
+
+// Definitions of other languages, ctags style: every pattern captures the defined name
+var (
+	jsDefinitions = []*regexp.Regexp{
+		regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*([\w$]+)`),
+		regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:abstract\s+)?class\s+([\w$]+)`),
+		regexp.MustCompile(`^\s*(?:export\s+)?(?:declare\s+)?(?:interface|type|enum|namespace)\s+([\w$]+)`),
+		regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+([\w$]+)\s*(?::[^=]*)?=`),
+		regexp.MustCompile(
+			`^\s*(?:(?:public|private|protected|static|async|readonly|get|set|override|abstract)\s+)*([\w$]+)\s*(?:<[^>]*>)?\([^;]*$`),
+	}
+	javaDefinitions = []*regexp.Regexp{
+		regexp.MustCompile(`\b(?:class|interface|enum|struct|record)\s+(\w+)`),
+		regexp.MustCompile(`^\s*(?:[\w<>\[\],.?@]+\s+)+(\w+)\s*\([^;]*$`),
+	}
+	cDefinitions = []*regexp.Regexp{
+		regexp.MustCompile(`^\s*(?:typedef\s+)?(?:struct|class|union|enum|namespace)\s+(\w+)`),
+		regexp.MustCompile(`^(?:[\w*&:<>,~]+[\s*&]+)*(?:\w+::)*(~?\w+)\s*\([^;]*$`),
+		regexp.MustCompile(`^\s*#define\s+(\w+)`),
+	}
+	definitionPatterns = map[string][]*regexp.Regexp{
+		"javascript": jsDefinitions,
+		"jsx":        jsDefinitions,
+		"typescript": jsDefinitions,
+		"tsx":        jsDefinitions,
+		"java":       javaDefinitions,
+		"csharp":     javaDefinitions,
+		"c":          cDefinitions,
+		"cpp":        cDefinitions,
+		"objectivec": cDefinitions,
+		"python": {
+			regexp.MustCompile(`^\s*(?:async\s+)?def\s+(\w+)`),
+			regexp.MustCompile(`^\s*class\s+(\w+)`),
+		},
+		"ruby": {
+			regexp.MustCompile(`^\s*def\s+(?:self\.)?(\w+[?!=]?)`),
+			regexp.MustCompile(`^\s*(?:class|module)\s+(?:\w+::)*(\w+)`),
+		},
+		"rust": {
+			regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?(?:extern\s+"\w+"\s+)?fn\s+(\w+)`),
+			regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|trait|type|mod|union|macro_rules!)\s*(\w+)`),
+			regexp.MustCompile(`^\s*(?:unsafe\s+)?impl(?:<[^>]*>)?\s+(?:[\w:]+(?:<[^>]*>)?\s+for\s+)?(?:\w+::)*(\w+)`),
+		},
+		"php": {
+			regexp.MustCompile(`\bfunction\s+&?(\w+)`),
+			regexp.MustCompile(`^\s*(?:abstract\s+|final\s+)?(?:class|interface|trait|enum)\s+(\w+)`),
+		},
+		"kotlin": {
+			regexp.MustCompile(`\bfun\s+(?:<[^>]*>\s*)?(?:[\w.]+\.)?(\w+)`),
+			regexp.MustCompile(`\b(?:class|interface|object)\s+(\w+)`),
+		},
+		"scala": {
+			regexp.MustCompile(`\bdef\s+(\w+)`),
+			regexp.MustCompile(`\b(?:class|object|trait)\s+(\w+)`),
+		},
+		"swift": {
+			regexp.MustCompile(`\bfunc\s+(\w+)`),
+			regexp.MustCompile(`\b(?:class|struct|enum|protocol|extension|actor)\s+(\w+)`),
+		},
+		"bash": {
+			regexp.MustCompile(`^\s*function\s+([\w-]+)`),
+			regexp.MustCompile(`^\s*([\w-]+)\s*\(\)`),
+		},
+	}
+)
+
+// Keywords that look like definitions to the patterns above
+var definitionKeywords = map[string]bool{
+	"if": true, "for": true, "foreach": true, "while": true, "switch": true, "catch": true, "return": true,
+	"new": true, "else": true, "do": true, "try": true, "throw": true, "using": true, "lock": true,
+	"synchronized": true, "sizeof": true, "typeof": true, "when": true, "function": true, "await": true,
+}
+
+// symbolDefinition is a definition found by the patterns, with line indexes
+type symbolDefinition struct {
+	name       string
+	start, end int
+}
+
+// Find the definitions of symbols in a file of a language with definition patterns. Doc comments,
+// decorators and attributes right above a definition are included. Matched symbols are marked in found.
+func definitionRanges(lines []SourceLine, language, module string, symbols [][]string, found []bool) []lineRange {
+	patterns := definitionPatterns[language]
+	if len(patterns) == 0 || len(lines) == 0 {
+		return nil
+	}
+	syntax := commentSyntaxes[language]
+	masked := strings.Split(maskSpans(joinLines(lines), syntax), "\n")
+
+	var definitions []symbolDefinition
+	for i, code := range masked {
+		for _, pattern := range patterns {
+			match := pattern.FindStringSubmatch(code)
+			if match == nil || definitionKeywords[match[1]] {
+				continue
+			}
+			end := braceDefinitionEnd(masked, i)
+			if indentOutlineLanguages[language] {
+				end = indentDefinitionEnd(lines, masked, i)
+			}
+			definitions = append(definitions, symbolDefinition{name: match[1], start: i, end: end})
+			break
+		}
+	}
+
+	var ranges []lineRange
+	for _, definition := range definitions {
+		// The innermost definition containing this one is its container
+		container := ""
+		for _, outer := range definitions {
+			if outer.start < definition.start && outer.end >= definition.end {
+				container = outer.name
+			}
+		}
+		for i, symbol := range symbols {
+			if !symbolMatches(symbol, definition.name, container, module) {
+				continue
+			}
+			found[i] = true
+			start := definition.start
+			for start > 0 && isDefinitionPrefix(lines[start-1].Text, syntax) {
+				start--
+			}
+			ranges = append(ranges, lineRange{lines[start].Number, lines[definition.end].Number})
+		}
+	}
+	if len(ranges) == 0 {
+		return nil
+	}
+	return mergeLineRanges(ranges, lines)
+}
+
+// Check if a line belongs to the definition below it: a comment, decorator or attribute
+func isDefinitionPrefix(text string, syntax commentSyntax) bool {
+	trimmed := strings.TrimSpace(text)
+	for _, prefix := range append([]string{"@", "#[", "/*", "*"}, syntax.lineComments...) {
+		if trimmed != "" && strings.HasPrefix(trimmed, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Index of the last line of a definition in a language with braces: the line closing its body,
+// or the end of the statement for definitions without body
+func braceDefinitionEnd(masked []string, start int) int {
+	indent := indentation(masked[start])
+	parens, depth := 0, 0
+	for i := start; i < len(masked); i++ {
+		for _, c := range []byte(masked[i]) {
+			switch {
+			case c == '(' || c == '[':
+				parens++
+			case c == ')' || c == ']':
+				parens--
+			case parens > 0:
+			case c == '{':
+				depth++
+			case c == '}' && depth > 0:
+				depth--
+				if depth == 0 {
+					return i
+				}
+			case c == ';' && depth == 0:
+				return i
+			}
+		}
+
+		// Without an open body the definition continues only on lines indented deeper or opening the body
+		if depth == 0 && parens <= 0 && i+1 < len(masked) {
+			next := strings.TrimSpace(masked[i+1])
+			if next == "" || indentation(masked[i+1]) <= indent && !strings.HasPrefix(next, "{") {
+				return i
+			}
+		}
+	}
+	return len(masked) - 1
+}
+
+// Index of the last line of a definition in a language structured by indentation
+func indentDefinitionEnd(lines []SourceLine, masked []string, start int) int {
+	indent := indentation(lines[start].Text)
+	end := start
+	// Signature may span lines until the parentheses are closed
+	parens := strings.Count(masked[end], "(") - strings.Count(masked[end], ")")
+	for parens > 0 && end+1 < len(masked) {
+		end++
+		parens += strings.Count(masked[end], "(") - strings.Count(masked[end], ")")
+	}
+
+	for i := end + 1; i < len(lines); i++ {
+		switch {
+		case strings.TrimSpace(lines[i].Text) == "":
+		case indentation(lines[i].Text) > indent:
+			end = i
+		case strings.TrimSpace(masked[i]) == "":
+			// Lines of strings and comments may have any indentation
+		default:
+			// Closing line of a Ruby definition
+			if strings.TrimSpace(masked[i]) == "end" && indentation(lines[i].Text) == indent {
+				end = i
+			}
+			return end
+		}
+	}
+	return end
+}
@@ -59,6 +59,56 @@ func matchGlobRecursive(pattern, name string) bool {
 	return (name) == ""
 }
 
+// Match a glob against a path relative to the project.
+// Patterns without a path separator are matched against the file name only.
+func matchPathGlob(pattern, relPath string) bool {
+	pattern = filepath.FromSlash(pattern)
+	if !strings.ContainsRune(pattern, filepath.Separator) {
+		return matchGlob(pattern, filepath.Base(relPath))
+	}
+	return matchGlob(pattern, relPath)
+}
+
+// Lower-case extension of a file, or the whole name for files without extension
+func fileExtension(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		ext = strings.ToLower(filepath.Base(filename))
+	}
+	return ext
+}
+
+// Split text into lines numbered from 1. A trailing line break does not start a new line.
+// Lines keep a carriage return of CRLF line breaks, so that the content is written unchanged.
+func splitSourceLines(text string) []SourceLine {
+	if text == "" {
+		return nil
+	}
+	parts := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	lines := make([]SourceLine, len(parts))
+	for i, part := range parts {
+		lines[i] = SourceLine{Number: i + 1, Text: part}
+	}
+	return lines
+}
+
+// Line replacing removed content
+func omissionMarker(count int, reason string) SourceLine {
+	noun := "lines"
+	if count == 1 {
+		noun = "line"
+	}
+	return SourceLine{Text: fmt.Sprintf("... %d %s omitted (%s) ...", count, noun, reason)}
+}
+
+// Upper-case the first letter of an ASCII word
+func capitalize(word string) string {
+	if word == "" {
+		return word
+	}
+	return strings.ToUpper(word[:1]) + word[1:]
+}
+
 // Format file size in human-readable format
 func formatFileSize(size int64) string {
 	const unit = 1024
@@ -89,6 +139,16 @@ func pathExists(path string) (bool, error) {
 	}
 	return false, err // другая ошибка
 }
+
+// Check if path is located strictly inside dir
+func isSubPath(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == "." || rel == ".." {
+		return false
+	}
+	return !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 func mergeMap[K comparable, V any](dst *map[K]V, src map[K]V) {
 	if len(src) == 0 {
 		return
@@ -101,3 +161,32 @@ func mergeMap[K comparable, V any](dst *map[K]V, src map[K]V) {
 		(*dst)[k] = v
 	}
 }
+
+// Create a new map with entries of dst overridden by entries of src
+func mergedMap[K comparable, V any](dst, src map[K]V) map[K]V {
+	result := make(map[K]V, len(dst)+len(src))
+	for k, v := range dst {
+		result[k] = v
+	}
+	for k, v := range src {
+		result[k] = v
+	}
+	return result
+}
+
+// stringList is a flag that can be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// Pointer to a copy of value, for optional settings
+func pointerTo[T any](value T) *T {
+	return &value
+}